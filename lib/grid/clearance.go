@@ -0,0 +1,98 @@
+package grid
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"math"
+)
+
+// Distance from the center of each cell to the edge of the nearest unpathable cell. Cells outside of the map
+// are unpathable. Single pathable cell between walls has clearance 0.5, unpathable cells have 0
+type ClearanceMap struct {
+	Width  int
+	Height int
+	Data   []float32
+}
+
+func NewClearanceMap(g *Grid) *ClearanceMap {
+	cm := &ClearanceMap{Width: g.PathingSizeX, Height: g.PathingSizeY}
+	cm.Data = make([]float32, cm.Width*cm.Height)
+	// Distance between centers of cells, computed by two pass chamfer transform with octile weights
+	dist := make([]float64, len(cm.Data))
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= cm.Width || y >= cm.Height {
+			return 0
+		}
+		return dist[x+y*cm.Width]
+	}
+	for y := 0; y < cm.Height; y++ {
+		for x := 0; x < cm.Width; x++ {
+			if !g.IsPathableFast(x, y) {
+				continue
+			}
+			dist[x+y*cm.Width] = math.Min(math.Min(at(x-1, y)+1, at(x, y-1)+1),
+				math.Min(at(x-1, y-1)+math.Sqrt2, at(x+1, y-1)+math.Sqrt2))
+		}
+	}
+	for y := cm.Height - 1; y >= 0; y-- {
+		for x := cm.Width - 1; x >= 0; x-- {
+			addr := x + y*cm.Width
+			if dist[addr] == 0 {
+				continue
+			}
+			dist[addr] = math.Min(dist[addr], math.Min(math.Min(at(x+1, y)+1, at(x, y+1)+1),
+				math.Min(at(x+1, y+1)+math.Sqrt2, at(x-1, y+1)+math.Sqrt2)))
+			cm.Data[addr] = float32(dist[addr] - 0.5)
+		}
+	}
+	return cm
+}
+
+// Clearance of the cell. Returns 0 outside of the map
+func (cm *ClearanceMap) At(ptr point.Pointer) float64 {
+	p := ptr.Point()
+	x, y := int(p.X()), int(p.Y())
+	if p.X() < 0 || p.Y() < 0 || x >= cm.Width || y >= cm.Height {
+		return 0
+	}
+	return float64(cm.Data[x+y*cm.Width])
+}
+
+// Unit with this radius can stand in the center of the cell. Unpathable cells and cells outside of the map never fit
+func (cm *ClearanceMap) Fits(ptr point.Pointer, radius float64) bool {
+	c := cm.At(ptr)
+	return c > 0 && c >= radius
+}
+
+// Closest cell where unit fits, not further than maxDist. Returns 0 if there is none
+func (cm *ClearanceMap) ClosestFitting(ptr point.Pointer, radius float64, maxDist int) point.Point {
+	pos := ptr.Point().Floor()
+	var best point.Point
+	bestDist := math.Inf(1)
+	for offset := 0; offset <= maxDist; offset++ {
+		for y := -offset; y <= offset; y++ {
+			for x := -offset; x <= offset; x++ {
+				if offset != 0 && x != offset && x != -offset && y != offset && y != -offset {
+					continue // Inner cells were checked already
+				}
+				p := pos + point.Pt(float64(x), float64(y))
+				if d := pos.Dist2(p); cm.Fits(p, radius) && d < bestDist {
+					best = p
+					bestDist = d
+				}
+			}
+		}
+		if best != 0 {
+			return best
+		}
+	}
+	return 0
+}
+
+// Pathfinder that uses only cells where unit with this radius fits
+func (cm *ClearanceMap) Pathfinder(radius float64) *Pathfinder {
+	pf := newPathfinder(cm.Width, cm.Height)
+	for n, c := range cm.Data {
+		pf.Pathable[n] = c > 0 && float64(c) >= radius
+	}
+	return pf
+}
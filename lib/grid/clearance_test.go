@@ -0,0 +1,83 @@
+package grid_test
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"strings"
+	"testing"
+)
+
+func TestNewClearanceMap_Corridor(t *testing.T) {
+	cm := grid.NewClearanceMap(testPictureGrid(
+		"########",
+		"........",
+		"########",
+	))
+	for x := 0.0; x < 8; x++ {
+		if c := cm.At(point.Pt(x, 1)); c != 0.5 {
+			t.Errorf("corridor cell %v: clearance %v, expected 0.5", x, c)
+		}
+		if c := cm.At(point.Pt(x, 0)); c != 0 {
+			t.Errorf("wall cell %v: clearance %v, expected 0", x, c)
+		}
+	}
+}
+
+func TestClearanceMap_Edges(t *testing.T) {
+	rows := make([]string, 16)
+	for n := range rows {
+		rows[n] = strings.Repeat(".", 16)
+	}
+	cm := grid.NewClearanceMap(testPictureGrid(rows...))
+	// Cells outside of the map are unpathable
+	for _, tc := range []struct {
+		p      point.Point
+		radius float64
+		fits   bool
+	}{
+		{point.Pt(0, 0), 0.5, true},
+		{point.Pt(0, 0), 1, false},
+		{point.Pt(15, 8), 0.5, true},
+		{point.Pt(15, 8), 1, false},
+		{point.Pt(1, 1), 1.5, true},
+		{point.Pt(-1, 0), 0, false},
+		{point.Pt(16, 0), 0, false},
+		{point.Pt(0, 16), 0, false},
+	} {
+		if fits := cm.Fits(tc.p, tc.radius); fits != tc.fits {
+			t.Errorf("Fits(%v, %v) = %v", tc.p, tc.radius, fits)
+		}
+	}
+
+	if p := cm.ClosestFitting(point.Pt(0, 0), 1.5, 4); p != point.Pt(1, 1) {
+		t.Errorf("ClosestFitting from the corner: %v", p)
+	}
+	if p := cm.ClosestFitting(point.Pt(15.5, 7.5), 1.5, 4); p != point.Pt(14, 7) {
+		t.Errorf("ClosestFitting from the side: %v", p)
+	}
+	if p := cm.ClosestFitting(point.Pt(-3, -3), 0.5, 2); p != 0 {
+		t.Errorf("ClosestFitting outside of the map: %v", p)
+	}
+	if p := cm.ClosestFitting(point.Pt(-3, -3), 0.5, 3); p != point.Pt(0, 0) {
+		t.Errorf("ClosestFitting into the map: %v", p)
+	}
+	if p := cm.ClosestFitting(point.Pt(8, 8), 10, 4); p != 0 {
+		t.Errorf("ClosestFitting for too large radius: %v", p)
+	}
+}
+
+func TestClearanceMap_PathfinderZeroRadius(t *testing.T) {
+	g := testPictureGrid(
+		"........",
+		"...#....",
+		"........",
+	)
+	pf := grid.NewClearanceMap(g).Pathfinder(0)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 8; x++ {
+			if pf.IsPathable(x, y) != g.IsPathable(point.Pt(float64(x), float64(y))) {
+				t.Errorf("cell (%v, %v): pathfinder and grid disagree", x, y)
+			}
+		}
+	}
+}
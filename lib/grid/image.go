@@ -0,0 +1,73 @@
+package grid
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"image"
+	"image/color"
+)
+
+type Layer int
+
+const (
+	LayerPathable Layer = iota + 1
+	LayerBuildable
+	LayerHeight
+	LayerCreep
+	LayerVisibility
+	LayerExplored
+)
+
+func (g *Grid) layerMap(layer Layer) *api.ImageData {
+	switch layer {
+	case LayerPathable:
+		return g.StartRaw.PathingGrid
+	case LayerBuildable:
+		return g.StartRaw.PlacementGrid
+	case LayerHeight:
+		return g.StartRaw.TerrainHeight
+	case LayerCreep:
+		return g.MapState.Creep
+	case LayerVisibility, LayerExplored:
+		return g.MapState.Visibility
+	}
+	return nil
+}
+
+// Value of the layer in the cell scaled to 0-255. Returns 0 if there is no data for the layer
+func (g *Grid) LayerAt(layer Layer, p point.Pointer) uint8 {
+	if m := g.layerMap(layer); m == nil || m.Size_ == nil {
+		return 0
+	}
+	ok := false
+	switch layer {
+	case LayerHeight:
+		return uint8(g.GetMapData(g.StartRaw.TerrainHeight, p))
+	case LayerPathable:
+		ok = g.IsPathable(p)
+	case LayerBuildable:
+		ok = g.IsBuildable(p)
+	case LayerCreep:
+		ok = g.IsCreep(p)
+	case LayerVisibility:
+		ok = g.IsVisible(p)
+	case LayerExplored:
+		ok = g.IsExplored(p)
+	}
+	if ok {
+		return 255
+	}
+	return 0
+}
+
+// Grayscale image of the layer, one pixel per cell. Top of the image is the top of the map (max Y)
+func (g *Grid) Image(layer Layer) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, g.PathingSizeX, g.PathingSizeY))
+	for y := 0; y < g.PathingSizeY; y++ {
+		for x := 0; x < g.PathingSizeX; x++ {
+			v := g.LayerAt(layer, point.Pt(float64(x), float64(y)))
+			img.SetGray(x, g.PathingSizeY-1-y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
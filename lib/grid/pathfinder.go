@@ -0,0 +1,416 @@
+package grid
+
+import (
+	"container/heap"
+	"github.com/aiseeq/s2l/lib/point"
+	"math"
+)
+
+// Pathfinder over the flat copy of the pathing grid. Jump point search is used when all cells cost the same,
+// plain A* is used when there are cost layers. Diagonal moves can't cut corners of obstacles
+type Pathfinder struct {
+	Width    int
+	Height   int
+	Pathable []bool
+	Cost     []float64 // Cost multiplier for entering the cell, nil - all cells cost the same
+	minCost  float64
+	g        []float64
+	parent   []int32
+	state    []uint32 // Search generation: == gen*2 - opened, == gen*2+1 - closed, other - not visited
+	gen      uint32
+	open     pfQueue
+}
+
+type pfItem struct {
+	addr int32
+	f    float64
+}
+type pfQueue []pfItem
+
+func (q pfQueue) Len() int            { return len(q) }
+func (q pfQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q pfQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pfQueue) Push(x interface{}) { *q = append(*q, x.(pfItem)) }
+func (q *pfQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func NewPathfinder(g *Grid) *Pathfinder {
+	pf := newPathfinder(g.PathingSizeX, g.PathingSizeY)
+	for y := 0; y < pf.Height; y++ {
+		for x := 0; x < pf.Width; x++ {
+			pf.Pathable[x+y*pf.Width] = g.IsPathableFast(x, y)
+		}
+	}
+	return pf
+}
+
+func newPathfinder(width, height int) *Pathfinder {
+	size := width * height
+	return &Pathfinder{
+		Width:    width,
+		Height:   height,
+		Pathable: make([]bool, size),
+		minCost:  1,
+		g:        make([]float64, size),
+		parent:   make([]int32, size),
+		state:    make([]uint32, size),
+	}
+}
+
+// Multiply cost of entering cells by values of the layer. Layer is indexed as x + y*Width, values should be > 0
+func (pf *Pathfinder) AddCostLayer(layer []float64) {
+	if pf.Cost == nil {
+		pf.Cost = make([]float64, len(pf.Pathable))
+		for n := range pf.Cost {
+			pf.Cost[n] = 1
+		}
+	}
+	pf.minCost = math.Inf(1)
+	for n := range pf.Cost {
+		if n < len(layer) {
+			pf.Cost[n] *= layer[n]
+		}
+		pf.minCost = math.Min(pf.minCost, pf.Cost[n])
+	}
+}
+
+// Remove all cost layers
+func (pf *Pathfinder) ResetCost() {
+	pf.Cost = nil
+	pf.minCost = 1
+}
+
+func (pf *Pathfinder) IsPathable(x, y int) bool {
+	return x >= 0 && y >= 0 && x < pf.Width && y < pf.Height && pf.Pathable[x+y*pf.Width]
+}
+
+func (pf *Pathfinder) addr(p point.Point) int {
+	x, y := int(p.X()), int(p.Y())
+	if !pf.IsPathable(x, y) || p.X() < 0 || p.Y() < 0 {
+		return -1
+	}
+	return x + y*pf.Width
+}
+
+func (pf *Pathfinder) point(addr int) point.Point {
+	return point.Pt(float64(addr%pf.Width), float64(addr/pf.Width))
+}
+
+func octile(dx, dy int) float64 {
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	if dx < dy {
+		dx, dy = dy, dx
+	}
+	return float64(dx-dy) + float64(dy)*math.Sqrt2
+}
+
+func sign(x int) int {
+	if x > 0 {
+		return 1
+	}
+	if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+// Move is allowed if target cell is pathable and diagonal move doesn't cut corners
+func (pf *Pathfinder) canMove(x, y, dx, dy int) bool {
+	if !pf.IsPathable(x+dx, y+dy) {
+		return false
+	}
+	return dx == 0 || dy == 0 || (pf.IsPathable(x+dx, y) && pf.IsPathable(x, y+dy))
+}
+
+func (pf *Pathfinder) start(from, to int) {
+	pf.gen++
+	if pf.gen >= math.MaxUint32/2 {
+		pf.gen = 1
+		for n := range pf.state {
+			pf.state[n] = 0
+		}
+	}
+	pf.open = pf.open[:0]
+	pf.g[from] = 0
+	pf.parent[from] = -1
+	pf.state[from] = pf.gen * 2
+	heap.Push(&pf.open, pfItem{int32(from), pf.heuristic(from, to)})
+}
+
+func (pf *Pathfinder) heuristic(from, to int) float64 {
+	return octile(from%pf.Width-to%pf.Width, from/pf.Width-to/pf.Width) * pf.minCost
+}
+
+// Add cell to the open list if new distance is better
+func (pf *Pathfinder) relax(from, addr, to int, g float64) {
+	if pf.state[addr] == pf.gen*2+1 {
+		return
+	}
+	if pf.state[addr] == pf.gen*2 && g >= pf.g[addr] {
+		return
+	}
+	pf.g[addr] = g
+	pf.parent[addr] = int32(from)
+	pf.state[addr] = pf.gen * 2
+	heap.Push(&pf.open, pfItem{int32(addr), g + pf.heuristic(addr, to)})
+}
+
+// Next cell from the open list. Returns -1 if the list is empty
+func (pf *Pathfinder) next() int {
+	for pf.open.Len() > 0 {
+		item := heap.Pop(&pf.open).(pfItem)
+		addr := int(item.addr)
+		if pf.state[addr] == pf.gen*2+1 {
+			continue // Already closed with a better distance
+		}
+		pf.state[addr] = pf.gen*2 + 1
+		return addr
+	}
+	return -1
+}
+
+// Cells from start to the given cell
+func (pf *Pathfinder) backtrace(addr int) point.Points {
+	var ps point.Points
+	for ; addr != -1; addr = int(pf.parent[addr]) {
+		ps = append(ps, pf.point(addr))
+	}
+	for i, j := 0, len(ps)-1; i < j; i, j = i+1, j-1 {
+		ps[i], ps[j] = ps[j], ps[i]
+	}
+	return ps
+}
+
+// A* over all 8 neighbours of each cell. Uses cost layers. Returns every cell of the path from start to end
+func (pf *Pathfinder) AStar(fromPtr, toPtr point.Pointer) (point.Points, float64) {
+	from, to := pf.addr(fromPtr.Point().Floor()), pf.addr(toPtr.Point().Floor())
+	if from == -1 || to == -1 {
+		return nil, 0
+	}
+	pf.start(from, to)
+	for cur := pf.next(); cur != -1; cur = pf.next() {
+		if cur == to {
+			return pf.backtrace(to), pf.g[to]
+		}
+		x, y := cur%pf.Width, cur/pf.Width
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx == 0 && dy == 0) || !pf.canMove(x, y, dx, dy) {
+					continue
+				}
+				addr := cur + dx + dy*pf.Width
+				cost := 1.0
+				if dx != 0 && dy != 0 {
+					cost = math.Sqrt2
+				}
+				if pf.Cost != nil {
+					cost *= pf.Cost[addr]
+				}
+				pf.relax(cur, addr, to, pf.g[cur]+cost)
+			}
+		}
+	}
+	return nil, 0
+}
+
+// Jump from the cell in the direction until something interesting is found. Returns -1 if there is nothing
+func (pf *Pathfinder) jump(x, y, dx, dy, to int) int {
+	for {
+		if !pf.IsPathable(x, y) {
+			return -1
+		}
+		addr := x + y*pf.Width
+		if addr == to {
+			return addr
+		}
+		switch {
+		case dx != 0 && dy != 0:
+			// Diagonal move stops where straight moves find something
+			if pf.jump(x+dx, y, dx, 0, to) != -1 || pf.jump(x, y+dy, 0, dy, to) != -1 {
+				return addr
+			}
+		case dx != 0:
+			// Forced neighbours: obstacle behind and free cell on the side
+			if (pf.IsPathable(x, y-1) && !pf.IsPathable(x-dx, y-1)) ||
+				(pf.IsPathable(x, y+1) && !pf.IsPathable(x-dx, y+1)) {
+				return addr
+			}
+		default:
+			if (pf.IsPathable(x-1, y) && !pf.IsPathable(x-1, y-dy)) ||
+				(pf.IsPathable(x+1, y) && !pf.IsPathable(x+1, y-dy)) {
+				return addr
+			}
+		}
+		if !pf.canMove(x, y, dx, dy) {
+			return -1
+		}
+		x += dx
+		y += dy
+	}
+}
+
+// Directions that should be checked from the cell reached from its parent
+func (pf *Pathfinder) directions(addr int) [][2]int {
+	x, y := addr%pf.Width, addr/pf.Width
+	var dirs [][2]int
+	parent := int(pf.parent[addr])
+	if parent == -1 {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if (dx != 0 || dy != 0) && pf.canMove(x, y, dx, dy) {
+					dirs = append(dirs, [2]int{dx, dy})
+				}
+			}
+		}
+		return dirs
+	}
+	dx, dy := sign(x-parent%pf.Width), sign(y-parent/pf.Width)
+	add := func(dx, dy int) {
+		if pf.canMove(x, y, dx, dy) {
+			dirs = append(dirs, [2]int{dx, dy})
+		}
+	}
+	switch {
+	case dx != 0 && dy != 0:
+		add(dx, 0)
+		add(0, dy)
+		add(dx, dy)
+	case dx != 0:
+		add(dx, 0)
+		add(dx, 1)
+		add(dx, -1)
+		add(0, 1)
+		add(0, -1)
+	default:
+		add(0, dy)
+		add(1, dy)
+		add(-1, dy)
+		add(1, 0)
+		add(-1, 0)
+	}
+	return dirs
+}
+
+// Jump point search. Ignores cost layers. Returns jump points of the path from start to end
+func (pf *Pathfinder) JPS(fromPtr, toPtr point.Pointer) (point.Points, float64) {
+	from, to := pf.addr(fromPtr.Point().Floor()), pf.addr(toPtr.Point().Floor())
+	if from == -1 || to == -1 {
+		return nil, 0
+	}
+	minCost := pf.minCost
+	pf.minCost = 1
+	defer func() { pf.minCost = minCost }()
+	pf.start(from, to)
+	for cur := pf.next(); cur != -1; cur = pf.next() {
+		if cur == to {
+			return pf.backtrace(to), pf.g[to]
+		}
+		x, y := cur%pf.Width, cur/pf.Width
+		for _, d := range pf.directions(cur) {
+			jp := pf.jump(x+d[0], y+d[1], d[0], d[1], to)
+			if jp == -1 {
+				continue
+			}
+			g := pf.g[cur] + octile(jp%pf.Width-x, jp/pf.Width-y)
+			pf.relax(cur, jp, to, g)
+		}
+	}
+	return nil, 0
+}
+
+// Every cell between consecutive points. Points should be on straight or diagonal lines like jump points
+func Expand(path point.Points) point.Points {
+	if path.Len() < 2 {
+		return path
+	}
+	ps := point.Points{path[0]}
+	for n := 1; n < path.Len(); n++ {
+		x, y := int(path[n-1].X()), int(path[n-1].Y())
+		x1, y1 := int(path[n].X()), int(path[n].Y())
+		dx, dy := sign(x1-x), sign(y1-y)
+		for x != x1 || y != y1 {
+			if x != x1 {
+				x += dx
+			}
+			if y != y1 {
+				y += dy
+			}
+			ps.Add(point.Pt(float64(x), float64(y)))
+		}
+	}
+	return ps
+}
+
+// Path that contains every cell from start to end. JPS is used if there are no cost layers
+func (pf *Pathfinder) Path(fromPtr, toPtr point.Pointer) (point.Points, float64) {
+	if pf.Cost != nil {
+		return pf.AStar(fromPtr, toPtr)
+	}
+	path, dist := pf.JPS(fromPtr, toPtr)
+	return Expand(path), dist
+}
+
+// Straight line between cell centers doesn't cross obstacles. All cells touched by the line are checked
+// (supercover traversal). Line through a corner of cells needs both cells near the corner like canMove does
+func (pf *Pathfinder) IsLineFree(p0, p1 point.Point) bool {
+	x, y := int(p0.X()), int(p0.Y())
+	x1, y1 := int(p1.X()), int(p1.Y())
+	dx, dy := x1-x, y1-y
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+	if !pf.IsPathable(x, y) {
+		return false
+	}
+	for ix, iy := 0, 0; ix < dx || iy < dy; {
+		// Compare distances to the next vertical and horizontal cell borders: (0.5+ix)/dx vs (0.5+iy)/dy
+		switch d := (1+2*ix)*dy - (1+2*iy)*dx; {
+		case d == 0:
+			if !pf.IsPathable(x+sx, y) || !pf.IsPathable(x, y+sy) {
+				return false
+			}
+			x, y, ix, iy = x+sx, y+sy, ix+1, iy+1
+		case d < 0:
+			x, ix = x+sx, ix+1
+		default:
+			y, iy = y+sy, iy+1
+		}
+		if !pf.IsPathable(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove points that could be skipped by walking straight
+func (pf *Pathfinder) Smooth(path point.Points) point.Points {
+	if path.Len() < 3 {
+		return path
+	}
+	ps := point.Points{path[0]}
+	for from := 0; from < path.Len()-1; {
+		next := from + 1
+		for n := path.Len() - 1; n > next; n-- {
+			if pf.IsLineFree(path[from], path[n]) {
+				next = n
+				break
+			}
+		}
+		ps.Add(path[next])
+		from = next
+	}
+	return ps
+}
@@ -0,0 +1,226 @@
+package grid_test
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/lib/scl"
+	"github.com/aiseeq/s2l/protocol/api"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Grid where cells of the pathable slice (indexed as x + y*width) are pathable
+func testGrid(width, height int, pathable []bool) *grid.Grid {
+	data := make([]byte, (width*height+7)/8)
+	for addr, ok := range pathable {
+		if ok {
+			data[addr/8] |= 1 << (7 - addr%8)
+		}
+	}
+	size := &api.Size2DI{X: int32(width), Y: int32(height)}
+	return grid.New(&api.StartRaw{
+		MapSize:     size,
+		PathingGrid: &api.ImageData{BitsPerPixel: 1, Size_: size, Data: data},
+	}, &api.MapState{})
+}
+
+// Grid drawn by rows starting from y = 0: '.' - pathable, '#' - not. Width is padded with obstacles
+// to a multiple of 8 because bitmaps of real maps are always aligned that way
+func testPictureGrid(rows ...string) *grid.Grid {
+	width := (len(rows[0]) + 7) / 8 * 8
+	pathable := make([]bool, width*len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			pathable[x+y*width] = c == '.'
+		}
+	}
+	return testGrid(width, len(rows), pathable)
+}
+
+// Grid with random rectangular obstacles
+func testRandomGrid(rnd *rand.Rand, size, obstacles int) *grid.Grid {
+	pathable := make([]bool, size*size)
+	for n := range pathable {
+		pathable[n] = true
+	}
+	for n := 0; n < obstacles; n++ {
+		x0, y0 := rnd.Intn(size), rnd.Intn(size)
+		w, h := 1+rnd.Intn(6), 1+rnd.Intn(6)
+		for y := y0; y < y0+h && y < size; y++ {
+			for x := x0; x < x0+w && x < size; x++ {
+				pathable[x+y*size] = false
+			}
+		}
+	}
+	return testGrid(size, size, pathable)
+}
+
+func randomPathable(rnd *rand.Rand, pf *grid.Pathfinder) point.Point {
+	for {
+		x, y := rnd.Intn(pf.Width), rnd.Intn(pf.Height)
+		if pf.IsPathable(x, y) {
+			return point.Pt(float64(x), float64(y))
+		}
+	}
+}
+
+func TestPathfinder_JPSEqualsAStar(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 20; n++ {
+		pf := grid.NewPathfinder(testRandomGrid(rnd, 64, 150))
+		for k := 0; k < 20; k++ {
+			from, to := randomPathable(rnd, pf), randomPathable(rnd, pf)
+			aPath, aDist := pf.AStar(from, to)
+			jPath, jDist := pf.JPS(from, to)
+			if (aPath == nil) != (jPath == nil) {
+				t.Fatalf("%v -> %v: A* found %v, JPS found %v", from, to, aPath != nil, jPath != nil)
+			}
+			if math.Abs(aDist-jDist) > 1e-6 {
+				t.Fatalf("%v -> %v: A* length %v, JPS length %v", from, to, aDist, jDist)
+			}
+			if jPath == nil {
+				continue
+			}
+			cells := grid.Expand(jPath)
+			if cells[0] != from || cells[len(cells)-1] != to {
+				t.Fatalf("%v -> %v: path goes from %v to %v", from, to, cells[0], cells[len(cells)-1])
+			}
+			for _, p := range cells {
+				if !pf.IsPathable(int(p.X()), int(p.Y())) {
+					t.Fatalf("%v -> %v: path crosses obstacle at %v", from, to, p)
+				}
+			}
+		}
+	}
+}
+
+func TestPathfinder_NoCornerCutting(t *testing.T) {
+	// Free cells (0,1) and (1,0) touch only by corners of blocked (0,0) and (1,1)
+	pf := grid.NewPathfinder(testPictureGrid(
+		"#..",
+		".#.",
+		".#.",
+	))
+	from, to := point.Pt(0, 1), point.Pt(1, 0)
+	for name, find := range map[string]func(a, b point.Pointer) (point.Points, float64){
+		"AStar": pf.AStar, "JPS": pf.JPS,
+	} {
+		if path, _ := find(from, to); path != nil {
+			t.Errorf("%s: path %v cuts the corner", name, path)
+		}
+	}
+
+	// One blocked corner is enough to forbid the diagonal step
+	pf = grid.NewPathfinder(testPictureGrid(
+		".#",
+		"..",
+	))
+	for name, find := range map[string]func(a, b point.Pointer) (point.Points, float64){
+		"AStar": pf.AStar, "JPS": pf.JPS,
+	} {
+		if _, dist := find(point.Pt(0, 0), point.Pt(1, 1)); dist != 2 {
+			t.Errorf("%s: length %v, expected 2", name, dist)
+		}
+	}
+}
+
+func TestPathfinder_IsLineFree(t *testing.T) {
+	pf := grid.NewPathfinder(testPictureGrid(
+		"..#.....",
+		".#......",
+		"........",
+		"....#...",
+	))
+	for _, c := range []struct {
+		p0, p1 point.Point
+		free   bool
+	}{
+		{point.Pt(0, 0), point.Pt(7, 0), false},
+		{point.Pt(0, 2), point.Pt(7, 2), true},
+		{point.Pt(1, 0), point.Pt(2, 1), false}, // Between corners of (2, 0) and (1, 1)
+		{point.Pt(0, 0), point.Pt(2, 2), false}, // Through the corner of (1, 1)
+		{point.Pt(2, 2), point.Pt(5, 5), false}, // Through the corner of (4, 3), point is outside of the map
+		{point.Pt(2, 2), point.Pt(4, 4), false}, // End of the line touches the corner of (4, 3)
+		{point.Pt(3, 1), point.Pt(7, 3), true},  // Passes above (4, 3)
+		{point.Pt(0, 2), point.Pt(6, 3), false}, // Crosses (4, 3) near its top border
+		{point.Pt(7, 3), point.Pt(3, 1), true},
+		{point.Pt(6, 3), point.Pt(0, 2), false},
+		{point.Pt(1, 1), point.Pt(1, 1), false},
+		{point.Pt(3, 3), point.Pt(3, 3), true},
+	} {
+		if free := pf.IsLineFree(c.p0, c.p1); free != c.free {
+			t.Errorf("%v -> %v: free %v, expected %v", c.p0, c.p1, free, c.free)
+		}
+	}
+
+	// Path from (1, 0) to (2, 1) goes around the diagonal gap and smoothing shouldn't cut through it
+	path, _ := pf.AStar(point.Pt(1, 0), point.Pt(2, 1))
+	if path == nil {
+		t.Fatal("path not found")
+	}
+	smooth := pf.Smooth(grid.Expand(path))
+	for n := 1; n < smooth.Len(); n++ {
+		if !pf.IsLineFree(smooth[n-1], smooth[n]) {
+			t.Errorf("smoothed path %v crosses obstacles", smooth)
+		}
+	}
+	if smooth.Len() < 4 {
+		t.Errorf("smoothed path %v cuts corners", smooth)
+	}
+}
+
+func TestPathfinder_Unreachable(t *testing.T) {
+	pf := grid.NewPathfinder(testPictureGrid(
+		"....#...",
+		"....#...",
+		"....#...",
+		"....#...",
+	))
+	from, to := point.Pt(1, 1), point.Pt(6, 2)
+	if path, dist := pf.AStar(from, to); path != nil || dist != 0 {
+		t.Errorf("AStar: %v, %v", path, dist)
+	}
+	if path, dist := pf.JPS(from, to); path != nil || dist != 0 {
+		t.Errorf("JPS: %v, %v", path, dist)
+	}
+	if path, _ := pf.Path(from, point.Pt(4, 2)); path != nil {
+		t.Errorf("Path to obstacle: %v", path)
+	}
+}
+
+func benchmarkPaths(bm *testing.B, find func(g *grid.Grid, pf *grid.Pathfinder, from, to point.Point)) {
+	rnd := rand.New(rand.NewSource(1))
+	g := testRandomGrid(rnd, 200, 300)
+	pf := grid.NewPathfinder(g)
+	var pairs [][2]point.Point
+	for len(pairs) < 20 {
+		from, to := randomPathable(rnd, pf), randomPathable(rnd, pf)
+		if path, _ := pf.JPS(from, to); path != nil {
+			pairs = append(pairs, [2]point.Point{from, to})
+		}
+	}
+	bm.ResetTimer()
+	for n := 0; n < bm.N; n++ {
+		pair := pairs[n%len(pairs)]
+		find(g, pf, pair[0], pair[1])
+	}
+}
+
+func BenchmarkPathfinder_Path(bm *testing.B) {
+	benchmarkPaths(bm, func(g *grid.Grid, pf *grid.Pathfinder, from, to point.Point) {
+		pf.Path(from, to)
+	})
+}
+
+func BenchmarkPathfinder_AStar(bm *testing.B) {
+	benchmarkPaths(bm, func(g *grid.Grid, pf *grid.Pathfinder, from, to point.Point) {
+		pf.AStar(from, to)
+	})
+}
+
+func BenchmarkTilesPath(bm *testing.B) {
+	benchmarkPaths(bm, func(g *grid.Grid, pf *grid.Pathfinder, from, to point.Point) {
+		scl.TilesPath(g, nil, to, from)
+	})
+}
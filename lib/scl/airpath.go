@@ -0,0 +1,161 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"math"
+	"sync"
+)
+
+const airThreatWeight = 0.3 // Air units are fragile and fast, they should prefer longer but safer routes
+const airBlockGap = 1       // Additional distance from static anti-air
+const airPathStep = 4       // Unit moves to the first path point further than this
+
+// Map accessor for flying units: whole playable area except cells covered by static anti-air
+type AirMap struct {
+	P0, P1  point.Point   // Playable area
+	Blocked *InfluenceMap // Cells covered by static anti-air, nil if they are not blocked
+}
+
+// Air maps of the current loop. Enemy structures don't change within the loop
+var airMaps = struct {
+	sync.Mutex
+	loop          int
+	blocked, free *AirMap
+}{loop: -1}
+
+// Air navigation layer. If blockStatic is set, cells in range of enemy anti-air structures are not passable
+func (b *Bot) NewAirMap(blockStatic bool) *AirMap {
+	pa := b.Info.StartRaw.PlayableArea
+	size := b.Info.StartRaw.MapSize
+	am := &AirMap{
+		P0: point.Pt(float64(pa.P0.X), float64(pa.P0.Y)),
+		P1: point.Pt(float64(pa.P1.X), float64(pa.P1.Y)),
+	}
+	if !blockStatic {
+		return am
+	}
+	am.Blocked = NewInfluenceMap(int(size.X), int(size.Y))
+	for _, u := range b.Enemies.AllReady {
+		if !u.IsStructure() || u.AirDPS() == 0 {
+			continue
+		}
+		r := u.AirRange() + float64(u.Radius) + airBlockGap
+		pos := u.Point()
+		for y := math.Floor(pos.Y() - r); y <= pos.Y()+r; y++ {
+			for x := math.Floor(pos.X() - r); x <= pos.X()+r; x++ {
+				p := point.Pt(x, y)
+				if p.CellCenter().IsCloserThan(r, pos) {
+					if addr := am.Blocked.addr(p); addr != -1 {
+						am.Blocked.Data[addr] = 1
+					}
+				}
+			}
+		}
+	}
+	return am
+}
+
+// Air map of the current loop. It is made once per loop for all air paths
+func (b *Bot) airMap(blockStatic bool) *AirMap {
+	airMaps.Lock()
+	defer airMaps.Unlock()
+	if airMaps.loop != b.Loop {
+		airMaps.loop = b.Loop
+		airMaps.blocked, airMaps.free = nil, nil
+	}
+	if !blockStatic {
+		if airMaps.free == nil {
+			airMaps.free = b.NewAirMap(false)
+		}
+		return airMaps.free
+	}
+	if airMaps.blocked == nil {
+		airMaps.blocked = b.NewAirMap(true)
+	}
+	return airMaps.blocked
+}
+
+func (am *AirMap) IsPathable(ptr point.Pointer) bool {
+	p := ptr.Point()
+	return p.X() >= am.P0.X() && p.Y() >= am.P0.Y() && p.X() < am.P1.X() && p.Y() < am.P1.Y() &&
+		(am.Blocked == nil || am.Blocked.At(p) == 0)
+}
+
+func (am *AirMap) IsBuildable(ptr point.Pointer) bool {
+	return false
+}
+
+func (am *AirMap) HeightAt(ptr point.Pointer) float64 {
+	return 0
+}
+
+// Is straight flight between points not worse than the path it replaces
+func (am *AirMap) canShortcut(from, to point.Point, maxThreat float64) bool {
+	dist := from.Dist(to)
+	for d := 0.0; d <= dist; d += 0.5 {
+		p := from.Towards(to, d)
+		if !am.IsPathable(p) || B.Influence.AirThreat.At(p) > maxThreat {
+			return false
+		}
+	}
+	return true
+}
+
+// Remove intermediate points where unit can fly straight without entering more dangerous cells
+func (am *AirMap) Smooth(path point.Points) point.Points {
+	if path.Len() < 3 {
+		return path
+	}
+	res := point.Points{path[0]}
+	for from := 0; from < path.Len()-1; {
+		// Highest threat on the original path between from and next
+		maxThreat := B.Influence.AirThreat.At(path[from])
+		next := from + 1
+		for n := from + 1; n < path.Len(); n++ {
+			maxThreat = math.Max(maxThreat, B.Influence.AirThreat.At(path[n]))
+			if !am.canShortcut(path[from], path[n], maxThreat) {
+				break
+			}
+			next = n
+		}
+		res.Add(path[next])
+		from = next
+	}
+	return res
+}
+
+// Smoothed air path from one point to another that avoids anti-air. Static anti-air is blocked if possible,
+// mobile one is weighted by its DPS
+func (b *Bot) AirPath(fromPtr, toPtr point.Pointer) point.Points {
+	am := b.airMap(true)
+	if am.canShortcut(fromPtr.Point(), toPtr.Point(), 0) {
+		return point.Points{fromPtr.Point(), toPtr.Point()} // Nothing to avoid
+	}
+	cost := b.Influence.AirThreat.Cost(airThreatWeight)
+	path, _ := TilesPath(am, cost, fromPtr, toPtr)
+	if path.Empty() {
+		// Unit or target is inside of the static defense range
+		am = b.airMap(false)
+		path, _ = TilesPath(am, cost, fromPtr, toPtr)
+	}
+	if path.Empty() {
+		return nil
+	}
+	for n, p := range path {
+		path[n] = p.CellCenter()
+	}
+	path[0] = fromPtr.Point()
+	path[path.Len()-1] = toPtr.Point()
+	return am.Smooth(path)
+}
+
+// Move flying unit to the target by the air path
+func (u *Unit) CommandAirPath(target point.Pointer) {
+	path := B.AirPath(u, target)
+	if pos := path.FirstFurtherThan(airPathStep, u); pos != 0 {
+		u.CommandPos(ability.Move, pos)
+		return
+	}
+	u.CommandPos(ability.Move, target)
+}
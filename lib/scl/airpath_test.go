@@ -0,0 +1,90 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"testing"
+)
+
+// Bot with a missile turret in the center of the map and no mobile anti-air
+func testAirBot(turret point.Point) *Bot {
+	b := testNavBot()
+	b.U.Attributes = map[api.UnitTypeID]map[api.Attribute]bool{
+		terran.MissileTurret: {api.Attribute_Structure: true},
+	}
+	b.U.Weapons = map[api.UnitTypeID]Weapon{
+		terran.MissileTurret: {air: &api.Weapon{Type: api.Weapon_Air, Range: 7}, airDps: 39},
+	}
+	u := &Unit{Unit: api.Unit{
+		UnitType:      terran.MissileTurret,
+		Pos:           &api.Point{X: float32(turret.X()), Y: float32(turret.Y())},
+		Radius:        1,
+		BuildProgress: 1,
+	}}
+	b.Enemies.AllReady = Units{u}
+	b.Influence.AirThreat = NewInfluenceMap(testMapSize, testMapSize)
+	airMaps.loop = -1 // Drop maps of other test bots
+	return b
+}
+
+func TestAirPath_AroundStaticAA(t *testing.T) {
+	turret := point.Pt(100, 100)
+	b := testAirBot(turret)
+	blockRadius := 7.0 + 1 + airBlockGap
+
+	from, to := point.Pt(100.5, 60.5), point.Pt(99.5, 140.5)
+	path := b.AirPath(from, to)
+	if path.Len() < 3 || path[0] != from || path[path.Len()-1] != to {
+		t.Fatalf("path: %v", path)
+	}
+	for n := 1; n < path.Len(); n++ {
+		for d := 0.0; d <= path[n-1].Dist(path[n]); d += 0.25 {
+			// Cells are blocked by their centers, so path can touch the blocked disk by a half of the cell diagonal
+			if p := path[n-1].Towards(path[n], d); p.IsCloserThan(blockRadius-0.71, turret) {
+				t.Fatalf("path %v goes through turret range at %v", path, p)
+			}
+		}
+	}
+	// Detour around the disk is only a bit longer than the straight line
+	length := 0.0
+	for n := 1; n < path.Len(); n++ {
+		length += path[n-1].Dist(path[n])
+	}
+	if length > from.Dist(to)+4 {
+		t.Errorf("path %v is too long: %v", path, length)
+	}
+
+	// Path without obstacles is straight
+	if path := b.AirPath(from, point.Pt(160.5, 60.5)); path.Len() != 2 {
+		t.Errorf("straight path: %v", path)
+	}
+	// Unit in range of the turret still gets a path
+	if path := b.AirPath(point.Pt(100.5, 95.5), to); path.Empty() || path[path.Len()-1] != to {
+		t.Errorf("path from turret range: %v", path)
+	}
+}
+
+func TestAirPath_MapCache(t *testing.T) {
+	b := testAirBot(point.Pt(100, 100))
+	b.Loop = 10
+	am := b.airMap(true)
+	if b.airMap(true) != am || b.airMap(false) == am {
+		t.Error("air maps are not cached within the loop")
+	}
+	if !am.IsPathable(point.Pt(50, 50)) || am.IsPathable(point.Pt(100, 100)) || am.IsPathable(point.Pt(1, 50)) {
+		t.Error("wrong blocked air map")
+	}
+	if free := b.airMap(false); free.Blocked != nil || !free.IsPathable(point.Pt(100, 100)) {
+		t.Error("wrong free air map")
+	}
+
+	b.Enemies.AllReady = nil
+	if b.airMap(true) != am {
+		t.Error("air map is changed within the loop")
+	}
+	b.Loop++
+	if b.airMap(true) == am || !b.airMap(true).IsPathable(point.Pt(100, 100)) {
+		t.Error("air map is not renewed in the next loop")
+	}
+}
@@ -0,0 +1,224 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"sort"
+)
+
+const longDistanceMaxThreat = 0 // Long distance miners are not sent where enemy can attack them
+
+// Worker allocator works on top of HandleMiners. It moves workers to new bases, ignores depleted ones and
+// sends workers that have nothing to do to long distance mining
+type WorkerAllocator struct {
+	Bases           TagsMap                     // Ready townhalls seen on the previous step
+	LongDistance    map[api.UnitTag]api.UnitTag // Miner -> mineral field at the base without townhall
+	MaxLongDistance int                         // Long distance mining is disabled if 0
+}
+
+func NewWorkerAllocator(maxLongDistance int) *WorkerAllocator {
+	return &WorkerAllocator{
+		Bases:           TagsMap{},
+		LongDistance:    map[api.UnitTag]api.UnitTag{},
+		MaxLongDistance: maxLongDistance,
+	}
+}
+
+// Ground distance between points. Straight distance is used if there is no ground path
+func GroundDist(from, to point.Pointer) float64 {
+	if path, dist := B.Path(to, from); path.Exists() {
+		return dist
+	}
+	return from.Point().Dist(to)
+}
+
+func baseMinerals(cc *Unit) Units {
+	return B.Units.Minerals.All().CloserThan(ResourceSpreadDistance, cc)
+}
+
+func baseGases(cc *Unit) Units {
+	return B.Units.My.OfType(terran.Refinery, terran.RefineryRich, zerg.Extractor, zerg.ExtractorRich,
+		protoss.Assimilator, protoss.AssimilatorRich).CloserThan(ResourceSpreadDistance, cc).Filter(func(u *Unit) bool {
+		return u.VespeneContents > 0
+	})
+}
+
+// Townhall has no minerals and no vespene left near it
+func IsDepleted(cc *Unit) bool {
+	if baseMinerals(cc).Exists() {
+		return false
+	}
+	for _, geyser := range B.Units.Geysers.All().CloserThan(ResourceSpreadDistance, cc) {
+		if geyser.VespeneContents > 0 {
+			return false
+		}
+	}
+	return baseGases(cc).Empty()
+}
+
+// Mineral miners assigned to the townhall
+func (wa *WorkerAllocator) mineralMiners(miners Units, cc *Unit) Units {
+	return miners.Filter(func(u *Unit) bool {
+		return B.Miners.CCForMiner[u.Tag] == cc.Tag && B.Miners.MineralForMiner[u.Tag] != 0
+	})
+}
+
+func (wa *WorkerAllocator) free(miner *Unit) {
+	delete(B.Miners.CCForMiner, miner.Tag)
+	delete(B.Miners.MineralForMiner, miner.Tag)
+	delete(B.Miners.GasForMiner, miner.Tag)
+	delete(wa.LongDistance, miner.Tag)
+}
+
+// Move workers to the new base: long distance miners first, then surplus of the bases that are closer by ground
+func (wa *WorkerAllocator) transfer(base *Unit, miners, ccs Units) {
+	mfs := baseMinerals(base)
+	wanted := 2 * mfs.Len()
+	var moving Units
+	for _, miner := range miners {
+		if wanted > moving.Len() && wa.LongDistance[miner.Tag] != 0 {
+			moving.Add(miner)
+		}
+	}
+	sources := ccs.Filter(func(u *Unit) bool { return u.Tag != base.Tag })
+	dists := map[api.UnitTag]float64{}
+	for _, cc := range sources {
+		dists[cc.Tag] = GroundDist(cc, base)
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return dists[sources[i].Tag] < dists[sources[j].Tag] })
+	for _, cc := range sources {
+		if moving.Len() >= wanted {
+			break
+		}
+		ccMiners := wa.mineralMiners(miners, cc)
+		surplus := ccMiners.Len() - 2*baseMinerals(cc).Len()
+		// Workers that are not carrying anything go first
+		ccMiners.OrderBy(func(u *Unit) float64 { return float64(len(u.BuffIds)) }, false)
+		for n := 0; n < surplus && moving.Len() < wanted; n++ {
+			moving.Add(ccMiners[n])
+		}
+	}
+	if moving.Empty() {
+		return
+	}
+	saturation := B.GetMineralsSaturation(mfs)
+	for _, miner := range moving {
+		var best *Unit
+		for _, mf := range mfs {
+			if saturation[mf.Tag] < 2 && (best == nil || saturation[mf.Tag] < saturation[best.Tag]) {
+				best = mf
+			}
+		}
+		if best == nil {
+			return
+		}
+		saturation[best.Tag]++
+		wa.free(miner)
+		// MicroMinerals will move the worker by the ground path
+		B.addMinerToMineral(miner, best, base)
+	}
+}
+
+// Mineral field for long distance mining: the least used one at the closest safe expansion without townhall
+func (wa *WorkerAllocator) longDistanceMineral() *Unit {
+	townhalls := B.Units.My.OfType(B.U.UnitAliases.For(terran.CommandCenter)...)
+	townhalls.Add(B.Units.My.OfType(B.U.UnitAliases.For(zerg.Hatchery)...)...)
+	townhalls.Add(B.Units.My.OfType(B.U.UnitAliases.For(protoss.Nexus)...)...)
+	for _, exp := range B.Locs.MyExps { // Expansions are sorted by distance from the start location
+		if townhalls.CloserThan(ResourceSpreadDistance, exp).Exists() ||
+			B.Enemies.All.CloserThan(ResourceSpreadDistance, exp).Exists() ||
+			(B.Influence.GroundThreat != nil && B.Influence.GroundThreat.At(exp) > longDistanceMaxThreat) {
+			continue
+		}
+		mfs := B.Units.Minerals.All().CloserThan(ResourceSpreadDistance, exp)
+		if mfs.Empty() {
+			continue
+		}
+		saturation := map[api.UnitTag]int{}
+		for _, mfTag := range wa.LongDistance {
+			saturation[mfTag]++
+		}
+		return mfs.Min(func(u *Unit) float64 { return float64(saturation[u.Tag]) })
+	}
+	return nil
+}
+
+func (wa *WorkerAllocator) microLongDistance(miners, enemies Units, safePos point.Pointer) {
+	for _, miner := range miners {
+		mfTag := wa.LongDistance[miner.Tag]
+		if mfTag == 0 {
+			continue
+		}
+		if enemies.CanAttack(miner, 2).Exists() {
+			// Base without townhall can't be defended
+			wa.free(miner)
+			miner.GroundFallback(safePos, false)
+			continue
+		}
+		if miner.EvadeEffects() || miner.IsReturning() {
+			continue
+		}
+		if miner.IsIdle() || (miner.IsGathering() && miner.TargetTag() != mfTag) {
+			miner.CommandTag(ability.Smart, mfTag)
+		}
+	}
+}
+
+// Call it instead of HandleMiners. Params are the same
+func (wa *WorkerAllocator) Step(miners, ccs, enemies Units, balance float64, safePos point.Pointer,
+	turrets point.Points) {
+	ccs = ccs.Filter(Ready)
+	active := ccs.Filter(func(u *Unit) bool { return !IsDepleted(u) })
+	// Miners of depleted bases are freed by HandleMiners because their townhalls are not in the list
+	if len(wa.Bases) > 0 {
+		for _, cc := range active {
+			if !wa.Bases[cc.Tag] {
+				wa.transfer(cc, miners, active)
+			}
+		}
+	}
+	wa.Bases = active.TagsMap()
+
+	// Long distance miners return to the usual mining when there are free places
+	mfs := B.Units.Minerals.All().TagsMap()
+	free := 0
+	for _, cc := range active {
+		free += 2*baseMinerals(cc).Len() - wa.mineralMiners(miners, cc).Len()
+	}
+	for _, miner := range miners {
+		if mfTag := wa.LongDistance[miner.Tag]; mfTag != 0 && (!mfs[mfTag] || free > 0) {
+			wa.free(miner)
+			free--
+		}
+	}
+
+	local := miners.Filter(func(u *Unit) bool { return wa.LongDistance[u.Tag] == 0 })
+	B.HandleMiners(local, active, enemies, balance, safePos, turrets)
+
+	// Workers that were not assigned by HandleMiners have nothing to do at our bases
+	for _, miner := range local {
+		if len(wa.LongDistance) >= wa.MaxLongDistance || active.Empty() {
+			break
+		}
+		if B.Miners.CCForMiner[miner.Tag] != 0 || B.Miners.MineralForMiner[miner.Tag] != 0 ||
+			B.Miners.GasForMiner[miner.Tag] != 0 {
+			continue
+		}
+		mf := wa.longDistanceMineral()
+		if mf == nil {
+			break
+		}
+		wa.LongDistance[miner.Tag] = mf.Tag
+		miner.CommandTag(ability.Smart, mf.Tag)
+	}
+	for tag := range wa.LongDistance {
+		if miners.ByTag(tag) == nil {
+			delete(wa.LongDistance, tag)
+		}
+	}
+	wa.microLongDistance(miners, enemies, safePos)
+}
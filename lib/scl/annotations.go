@@ -0,0 +1,130 @@
+package scl
+
+import (
+	"fmt"
+	"github.com/aiseeq/s2l/protocol/api"
+	"strings"
+	"sync"
+)
+
+// Common label categories. Any other string could be used too
+const (
+	LabelRole   = "role"
+	LabelTarget = "target"
+	LabelState  = "state"
+	LabelHPS    = "hps"
+)
+
+type annotation struct {
+	category string
+	text     string
+}
+
+// Labels attached to units during the current frame. They are drawn by DebugSend above the units in one command
+type Annotations struct {
+	Hidden map[string]bool // Categories that are not drawn
+	labels map[api.UnitTag][]annotation
+	mutex  sync.Mutex
+}
+
+// Attach label to the unit for this frame. Label of the same category replaces the previous one
+func (b *Bot) Annotate(tag api.UnitTag, category, text string) {
+	a := &b.Annotations
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.labels == nil {
+		a.labels = map[api.UnitTag][]annotation{}
+	}
+	for n, an := range a.labels[tag] {
+		if an.category == category {
+			a.labels[tag][n].text = text
+			return
+		}
+	}
+	a.labels[tag] = append(a.labels[tag], annotation{category, text})
+}
+
+func (b *Bot) ShowLabels(category string, show bool) {
+	a := &b.Annotations
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.Hidden == nil {
+		a.Hidden = map[string]bool{}
+	}
+	a.Hidden[category] = !show
+}
+
+// Label units with the abilities and targets of their orders
+func (b *Bot) AnnotateTargets(us Units) {
+	for _, u := range us {
+		if u.IsIdle() {
+			continue
+		}
+		text := b.abilityName(u.TargetAbility())
+		if tag := u.TargetTag(); tag != 0 {
+			if target := b.Units.ByTag[tag]; target != nil {
+				text += " -> " + b.typeName(target.UnitType)
+			}
+		} else if pos := u.TargetPos(); pos != 0 {
+			text += fmt.Sprintf(" -> (%.1f, %.1f)", pos.X(), pos.Y())
+		}
+		b.Annotate(u.Tag, LabelTarget, text)
+	}
+}
+
+func (b *Bot) abilityName(aid api.AbilityID) string {
+	if b.Data != nil && int(aid) < len(b.Data.Abilities) {
+		if ad := b.Data.Abilities[aid]; ad != nil && ad.AbilityId == aid && ad.FriendlyName != "" {
+			return ad.FriendlyName
+		}
+	}
+	return fmt.Sprint(aid)
+}
+
+func (b *Bot) typeName(uType api.UnitTypeID) string {
+	if int(uType) < len(b.U.Types) && b.U.Types[uType] != nil && b.U.Types[uType].Name != "" {
+		return b.U.Types[uType].Name
+	}
+	return fmt.Sprint(uType)
+}
+
+// Label damaged units with hits they lose per second
+func (b *Bot) AnnotateHPS(us Units) {
+	for _, u := range us {
+		if u.HPS > 0 {
+			b.Annotate(u.Tag, LabelHPS, fmt.Sprintf("hps: %.1f", u.HPS))
+		}
+	}
+}
+
+// Texts for visible labels of existing units. Labels are cleared
+func (b *Bot) annotationTexts() []*api.DebugText {
+	a := &b.Annotations
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var texts []*api.DebugText
+	for tag, ans := range a.labels {
+		u := b.Units.ByTag[tag]
+		if u == nil {
+			continue
+		}
+		var lines []string
+		for _, an := range ans {
+			if !a.Hidden[an.category] {
+				lines = append(lines, an.text)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		pos := u.Point().To3D()
+		pos.Z = float32(b.Grid.HeightAt(u)) + u.Radius + 0.5
+		texts = append(texts, &api.DebugText{
+			Color:    &White,
+			Text:     strings.Join(lines, "\n"),
+			WorldPos: pos,
+		})
+	}
+	a.labels = nil
+	return texts
+}
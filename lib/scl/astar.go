@@ -1,9 +1,11 @@
 package scl
 
 import (
+	"github.com/aiseeq/s2l/lib/grid"
 	"github.com/aiseeq/s2l/lib/point"
 	"github.com/beefsack/go-astar"
 	"math"
+	"sync"
 )
 
 type MapAccessor interface {
@@ -20,6 +22,8 @@ type Tile struct {
 	B MapAccessor
 	// Tiles storage
 	M map[float64]map[float64]*Tile
+	// Cost multiplier for entering the cell, nil - all cells cost the same
+	C func(p point.Point) float64
 }
 
 func (t *Tile) Map(x, y float64) *Tile {
@@ -34,7 +38,7 @@ func (t *Tile) Map(x, y float64) *Tile {
 	if ok {
 		return tile
 	}
-	tile = &Tile{x, y, t.B, t.M}
+	tile = &Tile{x, y, t.B, t.M, t.C}
 	row[x] = tile
 	t.M[y] = row
 	return tile
@@ -61,10 +65,14 @@ func (t *Tile) PathNeighborCost(to astar.Pather) float64 {
 	p2 := point.Pt(t2.X, t2.Y)
 	delta := p2 - p1
 
+	cost := 1.0
 	if delta.X() != 0 && delta.Y() != 0 {
-		return math.Sqrt2
+		cost = math.Sqrt2
 	}
-	return 1
+	if t.C != nil {
+		cost *= t.C(p2)
+	}
+	return cost
 }
 
 func (t *Tile) PathEstimatedCost(to astar.Pather) float64 {
@@ -81,9 +89,19 @@ func (t *Tile) PathEstimatedCost(to astar.Pather) float64 {
 
 // Params in reverse order because astar.Path returns reversed list
 func (b *Bot) Path(toPtr, fromPtr point.Pointer) (point.Points, float64) {
+	return TilesPath(b.Grid, nil, toPtr, fromPtr)
+}
+
+// Same as Path, but cells are more expensive where threat is higher. Use it to find the least dangerous route
+func (b *Bot) ThreatPath(toPtr, fromPtr point.Pointer, threat *InfluenceMap, weight float64) (point.Points, float64) {
+	return TilesPath(b.Grid, threat.Cost(weight), toPtr, fromPtr)
+}
+
+// A* path over the map cells with optional cost multiplier for cells
+func TilesPath(m MapAccessor, cost func(p point.Point) float64, toPtr, fromPtr point.Pointer) (point.Points, float64) {
 	from := fromPtr.Point().Floor()
 	to := toPtr.Point().Floor()
-	f := &Tile{X: from.X(), Y: from.Y(), B: b.Grid}
+	f := &Tile{X: from.X(), Y: from.Y(), B: m, C: cost}
 	t := f.Map(to.X(), to.Y())
 	// start := time.Now()
 	path, dist, found := astar.Path(f, t)
@@ -99,3 +117,133 @@ func (b *Bot) Path(toPtr, fromPtr point.Pointer) (point.Points, float64) {
 	}
 	return ps, dist
 }
+
+const clearanceSearchDist = 4 // Max distance for moving path ends to the cells where unit fits
+
+const pathfinderCacheLoops = 224 // Caches of grids that weren't used for 10 seconds are dropped
+
+// Clearance map and pathfinders for different unit radii built from one grid
+type gridPathfinders struct {
+	used      int // Last loop when the grid was checked for changes
+	hash      uint64
+	clearance *grid.ClearanceMap
+	finders   map[float64]*grid.Pathfinder
+}
+
+// Grids are changed in place, so their pathing data is compared with the cached one once per loop
+var pathfinders = struct {
+	sync.Mutex
+	loop  int
+	grids map[*grid.Grid]*gridPathfinders
+}{loop: -1}
+
+func gridCache(g *grid.Grid) *gridPathfinders {
+	if pathfinders.grids == nil {
+		pathfinders.grids = map[*grid.Grid]*gridPathfinders{}
+	}
+	if pathfinders.loop != B.Loop {
+		pathfinders.loop = B.Loop
+		for k, gp := range pathfinders.grids {
+			if B.Loop-gp.used > pathfinderCacheLoops {
+				delete(pathfinders.grids, k) // Grids of RenewPaths are recreated all the time
+			}
+		}
+	}
+	gp := pathfinders.grids[g]
+	if gp == nil {
+		gp = &gridPathfinders{used: -1}
+		pathfinders.grids[g] = gp
+	}
+	if gp.used != B.Loop {
+		gp.used = B.Loop
+		if hash := gridHash(g); hash != gp.hash || gp.finders == nil {
+			gp.hash = hash
+			gp.clearance = nil
+			gp.finders = map[float64]*grid.Pathfinder{}
+		}
+	}
+	return gp
+}
+
+func (gp *gridPathfinders) clearanceMap(g *grid.Grid) *grid.ClearanceMap {
+	if gp.clearance == nil {
+		gp.clearance = grid.NewClearanceMap(g)
+	}
+	return gp.clearance
+}
+
+// Clearance map of the grid, it is cached until pathing data of the grid changes
+func (b *Bot) Clearance(g *grid.Grid) *grid.ClearanceMap {
+	pathfinders.Lock()
+	defer pathfinders.Unlock()
+	return gridCache(g).clearanceMap(g)
+}
+
+// Call f with the pathfinder for the grid. Pathfinder can't be used by several goroutines at once
+func withPathfinder(g *grid.Grid, f func(pf *grid.Pathfinder)) {
+	withRadiusPathfinder(g, 0, f)
+}
+
+// Same as withPathfinder, but pathfinder uses only cells where unit with the radius fits
+func withRadiusPathfinder(g *grid.Grid, radius float64, f func(pf *grid.Pathfinder)) {
+	pathfinders.Lock()
+	defer pathfinders.Unlock()
+	gp := gridCache(g)
+	pf := gp.finders[radius]
+	if pf == nil {
+		if radius == 0 {
+			pf = grid.NewPathfinder(g)
+		} else {
+			pf = gp.clearanceMap(g).Pathfinder(radius)
+		}
+		gp.finders[radius] = pf
+	}
+	f(pf)
+}
+
+func reversed(ps point.Points) point.Points {
+	for i, j := 0, len(ps)-1; i < j; i, j = i+1, j-1 {
+		ps[i], ps[j] = ps[j], ps[i]
+	}
+	return ps
+}
+
+// Same as Path, but uses jump point search over the flat grid. Diagonal moves don't cut corners
+func (b *Bot) FastPath(toPtr, fromPtr point.Pointer) (ps point.Points, dist float64) {
+	withPathfinder(b.Grid, func(pf *grid.Pathfinder) {
+		pf.ResetCost()
+		ps, dist = pf.Path(fromPtr, toPtr)
+	})
+	return reversed(ps), dist
+}
+
+// Same as ThreatPath, but uses A* over the flat grid
+func (b *Bot) FastThreatPath(toPtr, fromPtr point.Pointer, threat *InfluenceMap,
+	weight float64) (ps point.Points, dist float64) {
+	layer := make([]float64, len(threat.Data))
+	for n, v := range threat.Data {
+		layer[n] = 1 + weight*v
+	}
+	withPathfinder(b.Grid, func(pf *grid.Pathfinder) {
+		pf.ResetCost()
+		pf.AddCostLayer(layer)
+		ps, dist = pf.Path(fromPtr, toPtr)
+		pf.ResetCost()
+	})
+	return reversed(ps), dist
+}
+
+// Same as FastPath, but only cells where unit with the radius fits are used. Start and end are moved
+// to the closest such cells. Ex: B.RadiusPath(target, thor, thor.Radius)
+func (b *Bot) RadiusPath(toPtr, fromPtr point.Pointer, radius float64) (ps point.Points, dist float64) {
+	cm := b.Clearance(b.Grid)
+	from := cm.ClosestFitting(fromPtr, radius, clearanceSearchDist)
+	to := cm.ClosestFitting(toPtr, radius, clearanceSearchDist)
+	if from == 0 || to == 0 {
+		return nil, 0
+	}
+	withRadiusPathfinder(b.Grid, radius, func(pf *grid.Pathfinder) {
+		ps, dist = pf.Path(from, to)
+	})
+	return reversed(ps), dist
+}
@@ -0,0 +1,23 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"testing"
+)
+
+func TestClearance_Cache(t *testing.T) {
+	b := testNavBot()
+	cm := b.Clearance(b.Grid)
+	b.Loop++
+	if b.Clearance(b.Grid) != cm {
+		t.Error("clearance map is rebuilt for unchanged grid")
+	}
+	b.Grid.SetPathable(point.Pt(100, 100), !b.Grid.IsPathable(point.Pt(100, 100)))
+	if b.Clearance(b.Grid) != cm {
+		t.Error("grid changes should be checked once per loop")
+	}
+	b.Loop++
+	if b.Clearance(b.Grid) == cm {
+		t.Error("clearance map isn't rebuilt after grid change")
+	}
+}
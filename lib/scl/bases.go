@@ -0,0 +1,128 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"sort"
+)
+
+type BaseOwner int
+
+const (
+	BaseFree BaseOwner = iota
+	BaseMine
+	BaseEnemy
+	BaseContested // Both players have structures there and at least one of them has a townhall
+)
+
+const baseTownhallDist = 3       // Townhall closer than this to the location occupies the base
+const baseEnemyDistWeight = 0.25 // How much distance to the enemy matters compared to the distance from our main
+
+type Base struct {
+	Location  point.Point
+	MyDist    float64 // Ground distance from our main
+	EnemyDist float64 // Ground distance from the enemy main
+	Owner     BaseOwner
+	SeenLoop  int // Last loop when the owner was changed
+}
+
+// Bases are sorted by ground distance from our main. Our main is always the first one
+func (b *Bot) InitBases(locs point.Points, myDists, enemyDists []float64) {
+	enemyDist := b.RequestPathing(b.Locs.EnemyStart, b.Locs.MyStart)
+	if enemyDist == 0 {
+		enemyDist = b.Locs.EnemyStart.Dist(b.Locs.MyStart) * 100
+	}
+	b.Bases = []*Base{{Location: b.Locs.MyStart, EnemyDist: enemyDist}}
+	for n, loc := range locs {
+		b.Bases = append(b.Bases, &Base{Location: loc, MyDist: myDists[n], EnemyDist: enemyDists[n]})
+	}
+	sort.SliceStable(b.Bases, func(i, j int) bool { return b.Bases[i].MyDist < b.Bases[j].MyDist })
+	b.UpdateBases()
+}
+
+func isTownhall(u *Unit) bool {
+	return u.Is(terran.CommandCenter, terran.OrbitalCommand, terran.PlanetaryFortress,
+		zerg.Hatchery, zerg.Lair, zerg.Hive, protoss.Nexus)
+}
+
+func (b *Bot) isStartLocation(base *Base) bool {
+	return base.Location == b.Locs.MyStart || b.Locs.EnemyStarts.CloserThan(baseTownhallDist, base.Location).Exists()
+}
+
+// Refresh bases ownership using townhalls seen. Enemy townhalls are remembered until their position is scouted
+func (b *Bot) UpdateBases() {
+	for _, base := range b.Bases {
+		myStructures := b.Units.MyAll.CloserThan(ResourceSpreadDistance, base.Location).Filter(Structure)
+		enemyStructures := b.Enemies.All.CloserThan(ResourceSpreadDistance, base.Location).Filter(Structure)
+		mine := myStructures.CloserThan(baseTownhallDist, base.Location).First(isTownhall) != nil
+		enemy := enemyStructures.CloserThan(baseTownhallDist, base.Location).First(isTownhall) != nil
+		owner := BaseFree
+		switch {
+		case (mine || enemy) && myStructures.Exists() && enemyStructures.Exists():
+			owner = BaseContested
+		case mine:
+			owner = BaseMine
+		case enemy:
+			owner = BaseEnemy
+		}
+		if owner != base.Owner {
+			base.Owner = owner
+			base.SeenLoop = b.Loop
+		}
+	}
+}
+
+func (b *Bot) BasesOf(owner BaseOwner) []*Base {
+	var bases []*Base
+	for _, base := range b.Bases {
+		if base.Owner == owner {
+			bases = append(bases, base)
+		}
+	}
+	return bases
+}
+
+// Base is safe to expand if there are no known enemies and no enemy threat near it
+func (b *Bot) IsBaseSafe(base *Base) bool {
+	if b.Enemies.All.CloserThan(ResourceSpreadDistance, base.Location).Filter(NotWorker).Exists() {
+		return false
+	}
+	return b.Influence.GroundThreat == nil || b.Influence.GroundThreat.At(base.Location) == 0
+}
+
+// Free safe base that is close to our main and far from the enemy. Townhall placement is validated by the game.
+// Returns nil if there is none
+func (b *Bot) NextExpansion() *Base {
+	var best *Base
+	bestScore := 0.0
+	for _, base := range b.PlaceableBases(b.BasesOf(BaseFree)) {
+		if b.isStartLocation(base) || !b.IsBaseSafe(base) {
+			continue
+		}
+		score := base.MyDist - baseEnemyDistWeight*base.EnemyDist
+		if best == nil || score < bestScore {
+			best = base
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// Free base that enemy will most likely take next: close to its main and far from ours. Returns nil if there is none
+func (b *Bot) EnemyNextExpansion() *Base {
+	var best *Base
+	bestScore := 0.0
+	for _, base := range b.BasesOf(BaseFree) {
+		if b.isStartLocation(base) {
+			continue
+		}
+		score := base.EnemyDist - baseEnemyDistWeight*base.MyDist
+		if best == nil || score < bestScore {
+			best = base
+			bestScore = score
+		}
+	}
+	return best
+}
@@ -25,7 +25,11 @@ type Bot struct {
 	Actions       actions.Actions
 	Cmds          *CommandsStack
 	DebugCommands []*api.DebugCommand
+	Dashboard     *Dashboard      // Web view of the map, nil if it is not started
+	Annotations   Annotations     // Unit labels of the current frame
 	RecentEffects [][]*api.Effect // This needed because corrosive biles disappear from effects to early
+	EffectZones   []EffectZone    // Dangerous effects for pathing
+	effectsSeen   map[effectKey]int
 
 	Locs struct {
 		MapCenter       point.Point
@@ -85,6 +89,15 @@ type Bot struct {
 		LastSeen         map[api.UnitTag]int
 	}
 
+	Influence struct {
+		GroundThreat *InfluenceMap // Enemy DPS against ground units
+		AirThreat    *InfluenceMap // Enemy DPS against air units
+		Detection    *InfluenceMap // Number of enemy detectors
+		Support      *InfluenceMap // Our DPS
+	}
+
+	Bases          []*Base
+	Terrain        *Terrain
 	Grid           *grid.Grid
 	SafeGrid       *grid.Grid
 	ReaperGrid     *grid.Grid
@@ -104,6 +117,7 @@ type Bot struct {
 	FramesPerOrder  int
 	Groups          *Groups
 	MaxGroup        GroupID
+	Squads          *Squads
 	Upgrades        map[api.AbilityID]bool
 
 	Loop             int
@@ -228,6 +242,7 @@ func (b *Bot) Init(stop <-chan struct{}) {
 	b.FindExpansions()
 	b.FindRamps()
 	b.InitRamps()
+	b.Terrain = b.AnalyzeTerrain()
 	go b.RenewPaths(stop)
 }
 
@@ -303,6 +318,11 @@ func (b *Bot) ParseUnits() {
 	} else {
 		b.Groups.ClearUnits()
 	}
+	if b.Squads == nil {
+		b.Squads = NewSquads()
+	} else {
+		b.Squads.ClearUnits()
+	}
 	b.Units.AllEnemy = UnitsByTypes{}
 
 	for _, unit := range b.Obs.RawData.Units {
@@ -312,6 +332,10 @@ func (b *Bot) ParseUnits() {
 		case api.Alliance_Self:
 			b.Units.My.Add(unit.UnitType, u)
 			b.Groups.Fill(u)
+			b.Squads.Fill(u)
+			if isNew {
+				b.Squads.Assign(u) // Reinforcements by squads rules
+			}
 			if isNew && b.UnitCreatedCallback != nil {
 				b.UnitCreatedCallback(u)
 			}
@@ -359,51 +383,11 @@ func (b *Bot) ParseUnits() {
 					}
 				}
 			} else { // api.Alliance_Neutral
-				var size BuildingSize = 0
-				var unpathable bool
-				pos := u.Point()
-				if u.IsMineral() {
-					size = S2x1
-					pos -= 1
-					unpathable = true
-				} else {
-					size = DestructibleSize[u.UnitType]
-					switch size {
-					case UnbuildableRocks:
-						pos -= 1 + 1i
-					case BreakableRocks2x2:
-						pos -= 1 + 1i
-						unpathable = true
-					case BreakableRocks4x4:
-						unpathable = true
-					case BreakableRocks4x2:
-						unpathable = true
-					case BreakableRocks2x4:
-						unpathable = true
-					case BreakableRocks6x2:
-						unpathable = true
-					case BreakableRocks2x6:
-						unpathable = true
-					case BreakableRocks6x6:
-						unpathable = true
-					case BreakableRocksDiagBLUR:
-						unpathable = true
-					case BreakableRocksDiagULBR:
-						unpathable = true
-					case BreakableHorizontalHuge:
-						unpathable = true
-					case BreakableVerticalHuge:
-						unpathable = true
-					default:
-						// log.Info(u.UnitType)
-					}
-				}
-				if size != 0 {
-					for _, p := range b.GetBuildingPoints(pos, size) {
-						b.Grid.SetBuildable(p, false)
-						if unpathable {
-							b.Grid.SetPathable(p, false)
-						}
+				ps, unpathable := b.NeutralFootprint(u)
+				for _, p := range ps {
+					b.Grid.SetBuildable(p, false)
+					if unpathable {
+						b.Grid.SetPathable(p, false)
 					}
 				}
 			}
@@ -436,6 +420,10 @@ func (b *Bot) ParseUnits() {
 	b.Enemies.All = b.Units.AllEnemy.All()           // All enemy units including those that are not visible now
 	b.Enemies.AllReady = b.Enemies.All.Filter(Ready) // Same but filter ready only
 	b.Enemies.Visible = b.Units.Enemy.All()          // All enemy units that are currently visible
+	b.Squads.RemoveLost()                            // Uses passengers of MyAll
+	b.UpdateEffectZones()
+	b.UpdateInfluence()
+	b.UpdateBases()
 
 	b.RequestAvailableAbilities(false, b.Units.MyAll...)
 	b.RequestAvailableAbilities(true, b.Units.MyAll...)
@@ -549,10 +537,29 @@ func (b *Bot) SaveState() {
 	}
 }
 
-/*func (b *Bot) LoadState() {
-	info := &tests.AgentInfo{}
-	info.LoadObservation("data/state/observation.bin")
-	info.LoadData("data/state/data.bin")
-	info.LoadInfo("data/state/info.bin")
-	b.Info = info
-}*/
+// Restore observation, data and game info saved by SaveState. Grid is created from them, so map analysis
+// and rendering can be done without the game
+func (b *Bot) LoadState() {
+	log.Info("Loading state")
+	load := func(file string, msg interface{ Unmarshal([]byte) error }) {
+		bytes, err := os.ReadFile("data/state/" + file + ".bin")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := msg.Unmarshal(bytes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	b.Obs = &api.Observation{}
+	b.Data = &api.ResponseData{}
+	b.Info = &api.ResponseGameInfo{}
+	load("observation", b.Obs)
+	load("data", b.Data)
+	load("info", b.Info)
+	b.Loop = int(b.Obs.GameLoop)
+	mapState := b.Obs.RawData.GetMapState()
+	if mapState == nil {
+		mapState = &api.MapState{}
+	}
+	b.Grid = grid.New(b.Info.StartRaw, mapState)
+}
@@ -0,0 +1,184 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"math"
+)
+
+type TumorState int
+
+const (
+	TumorCooldown TumorState = iota + 1 // Tumor is not ready to spread yet
+	TumorActive                         // Tumor can spawn a new one
+	TumorUsed                           // Tumor has already spawned a new one
+)
+
+const creepTumorRange = 10      // Max distance for a new tumor from the old one
+const creepRadius = 10          // Creep radius around a tumor
+const creepTumorSpacing = 5     // Min distance between tumors
+const creepQueenRange = 8       // How far from its position queen may go to place a tumor
+const creepPathWidth = 6        // Candidates that are further from the path don't get progress score
+const creepQueenEnergy = 25     // Energy cost of the queen's tumor
+const creepCoverageWeight = 0.1 // Score for each newly covered cell. Progress along the path gives 1 per cell
+const creepPlannedLoops = 224   // Planned position is forgotten if tumor doesn't appear there in 10 sec
+
+type CreepManager struct {
+	Queens  Tags         // Queens that spread creep. Other queens are not touched
+	Targets point.Points // Where creep should go
+	States  map[api.UnitTag]TumorState
+	paths   map[point.Point]point.Points
+	planned map[point.Point]int // Positions of tumors ordered but not yet created -> loop of the order
+}
+
+func NewCreepManager(targets ...point.Point) *CreepManager {
+	return &CreepManager{
+		Targets: targets,
+		States:  map[api.UnitTag]TumorState{},
+		paths:   map[point.Point]point.Points{},
+		planned: map[point.Point]int{},
+	}
+}
+
+func (cm *CreepManager) Tumors() Units {
+	return B.Units.My.OfType(zerg.CreepTumor, zerg.CreepTumorBurrowed, zerg.CreepTumorQueen)
+}
+
+// Refresh tumors states. Active tumor that lost its ability is used
+func (cm *CreepManager) updateStates() {
+	tumors := cm.Tumors()
+	alive := map[api.UnitTag]bool{}
+	for _, tumor := range tumors {
+		alive[tumor.Tag] = true
+		state := cm.States[tumor.Tag]
+		switch {
+		case tumor.HasAbility(ability.Build_CreepTumor_Tumor):
+			cm.States[tumor.Tag] = TumorActive // Also if the last order failed
+		case state == TumorUsed:
+		case state == TumorActive:
+			cm.States[tumor.Tag] = TumorUsed
+		default:
+			cm.States[tumor.Tag] = TumorCooldown
+		}
+	}
+	for tag := range cm.States {
+		if !alive[tag] {
+			delete(cm.States, tag)
+		}
+	}
+	// Tumors that were created or not created in time are not planned anymore
+	for p, loop := range cm.planned {
+		if tumors.CloserThan(1, p).Exists() || loop+creepPlannedLoops < B.Loop {
+			delete(cm.planned, p)
+		}
+	}
+}
+
+// Ground path from our start location to the target. Calculated once
+func (cm *CreepManager) path(target point.Point) point.Points {
+	if path, ok := cm.paths[target]; ok {
+		return path
+	}
+	path, _ := B.Path(B.Locs.MyStart, target)
+	cm.paths[target] = path
+	return path
+}
+
+// Is it possible and safe to place a tumor there
+func (cm *CreepManager) isPosOk(p point.Point) bool {
+	if !B.Grid.IsCreep(p) || !B.Grid.IsBuildable(p) || !B.Grid.IsVisible(p) {
+		return false
+	}
+	if B.Influence.Detection != nil && (B.Influence.Detection.At(p) > 0 || B.Influence.GroundThreat.At(p) > 0) {
+		return false
+	}
+	if cm.Tumors().CloserThan(creepTumorSpacing, p).Exists() {
+		return false
+	}
+	for pp := range cm.planned {
+		if pp.IsCloserThan(creepTumorSpacing, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Number of pathable cells without creep that tumor at p will cover
+func coverage(p point.Point) int {
+	cells := 0
+	for y := p.Y() - creepRadius; y <= p.Y()+creepRadius; y++ {
+		for x := p.X() - creepRadius; x <= p.X()+creepRadius; x++ {
+			c := point.Pt(x, y)
+			if c.IsCloserThan(creepRadius, p) && B.Grid.IsPathable(c) && !B.Grid.IsCreep(c) {
+				cells++
+			}
+		}
+	}
+	return cells
+}
+
+// How far along the paths to targets the point is
+func (cm *CreepManager) progress(p point.Point) float64 {
+	best := 0.0
+	for _, target := range cm.Targets {
+		path := cm.path(target)
+		for n, pp := range path {
+			if pp.IsCloserThan(creepPathWidth, p) {
+				best = math.Max(best, float64(n))
+			}
+		}
+	}
+	return best
+}
+
+// Best position for a new tumor within radius from the center. Returns 0 if there is no good position
+func (cm *CreepManager) FindTumorPos(center point.Point, radius float64) point.Point {
+	var best point.Point
+	bestScore := 0.0
+	c := center.Floor()
+	for y := c.Y() - radius; y <= c.Y()+radius; y++ {
+		for x := c.X() - radius; x <= c.X()+radius; x++ {
+			p := point.Pt(x, y)
+			if !p.IsCloserThan(radius, c) || !cm.isPosOk(p) {
+				continue
+			}
+			cov := coverage(p)
+			if cov == 0 {
+				continue // Nothing new
+			}
+			score := cm.progress(p) + float64(cov)*creepCoverageWeight
+			if score > bestScore {
+				best = p.CellCenter()
+				bestScore = score
+			}
+		}
+	}
+	return best
+}
+
+// Update tumors states and send spread orders to active tumors and creep queens
+func (cm *CreepManager) Step() {
+	cm.updateStates()
+	for _, tumor := range cm.Tumors() {
+		if cm.States[tumor.Tag] != TumorActive || tumor.TargetAbility() == ability.Build_CreepTumor_Tumor {
+			continue
+		}
+		if pos := cm.FindTumorPos(tumor.Point(), creepTumorRange); pos != 0 {
+			tumor.CommandPos(ability.Build_CreepTumor_Tumor, pos)
+			cm.States[tumor.Tag] = TumorUsed
+			cm.planned[pos] = B.Loop
+		}
+	}
+
+	for _, queen := range B.Units.My[zerg.Queen].ByTags(cm.Queens) {
+		if queen.Energy < creepQueenEnergy || queen.TargetAbility() == ability.Build_CreepTumor_Queen {
+			continue
+		}
+		if pos := cm.FindTumorPos(queen.Point(), creepQueenRange); pos != 0 {
+			queen.CommandPos(ability.Build_CreepTumor_Queen, pos)
+			cm.planned[pos] = B.Loop
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"math"
+)
+
+// Damage added by each weapon upgrade level. Base is added to weapon damage, Bonus is added to attribute bonuses
+type DamageUpgrade struct {
+	Base, Bonus float64
+}
+
+const minDamage = 0.5 // Armor can't reduce damage of a single attack below this value
+
+// Units not listed here receive +1 base damage per level. Structures and workers receive nothing
+var WeaponUpgrades = map[api.UnitTypeID]DamageUpgrade{
+	terran.Marauder:          {1, 1},
+	terran.Ghost:             {1, 1},
+	terran.Hellion:           {1, 1},
+	terran.HellionTank:       {2, 0},
+	terran.WidowMine:         {0, 0},
+	terran.WidowMineBurrowed: {0, 0},
+	terran.SiegeTank:         {2, 1},
+	terran.SiegeTankSieged:   {4, 1},
+	terran.Cyclone:           {2, 0},
+	terran.Thor:              {3, 1},
+	terran.ThorAP:            {3, 1},
+	terran.VikingFighter:     {1, 1},
+	terran.LiberatorAG:       {5, 0},
+	terran.AutoTurret:        {0, 0},
+	terran.KD8Charge:         {0, 0},
+	zerg.Baneling:            {2, 2},
+	zerg.Roach:               {2, 0},
+	zerg.Ravager:             {2, 0},
+	zerg.LurkerMPBurrowed:    {2, 1},
+	zerg.Corruptor:           {1, 1},
+	zerg.BroodLord:           {2, 0},
+	zerg.Ultralisk:           {3, 0},
+	zerg.LocustMP:            {1, 0},
+	protoss.Zealot:           {1, 0},
+	protoss.Stalker:          {1, 1},
+	protoss.Adept:            {1, 1},
+	protoss.DarkTemplar:      {5, 0},
+	protoss.Archon:           {3, 1},
+	protoss.Immortal:         {2, 3},
+	protoss.Colossus:         {1, 1},
+	protoss.VoidRay:          {1, 1},
+	protoss.Tempest:          {4, 1},
+	protoss.Disruptor:        {0, 0},
+	protoss.Oracle:           {0, 0},
+}
+
+func (u *Unit) weaponUpgrade() DamageUpgrade {
+	if du, ok := WeaponUpgrades[u.UnitType]; ok {
+		return du
+	}
+	if u.IsStructure() || u.IsWorker() {
+		return DamageUpgrade{}
+	}
+	return DamageUpgrade{Base: 1}
+}
+
+// Weapon that unit uses against the target or nil if it can't attack it
+func (u *Unit) WeaponAgainst(target *Unit) *api.Weapon {
+	w := B.U.Weapons[u.UnitType]
+	// Air weapon is checked first because it is always the one used vs colossus by anti-air units
+	if w.air != nil && Flying(target) {
+		return w.air
+	}
+	if w.ground != nil && Ground(target) {
+		return w.ground
+	}
+	return nil
+}
+
+// Damage of a single attack vs target before armor is applied. Attack upgrades and attribute bonuses are included
+func (u *Unit) AttackDamage(target *Unit) float64 {
+	weapon := u.WeaponAgainst(target)
+	if weapon == nil {
+		return 0
+	}
+	du := u.weaponUpgrade()
+	level := float64(u.AttackUpgradeLevel)
+	damage := float64(weapon.Damage) + du.Base*level
+	for _, db := range weapon.DamageBonus {
+		if B.U.Attributes[target.UnitType][db.Attribute] {
+			damage += float64(db.Bonus) + du.Bonus*level
+		}
+	}
+	return damage
+}
+
+// Health armor of the unit including upgrades
+func (u *Unit) Armor() float64 {
+	armor := float64(B.U.Types[u.UnitType].Armor)
+	level := float64(u.ArmorUpgradeLevel)
+	if u.IsStructure() && B.U.Types[u.UnitType].Race == api.Race_Terran {
+		level *= 2 // Neosteel armor gives +2 for buildings
+	}
+	return armor + level
+}
+
+// Shield armor of the unit. It depends only on shields upgrade level
+func (u *Unit) ShieldArmor() float64 {
+	return float64(u.ShieldUpgradeLevel)
+}
+
+// Apply one attack to health and shields values. Returns new values
+func applyAttack(target *Unit, damage, health, shield float64) (float64, float64) {
+	if shield > 0 {
+		dealt := math.Max(damage-target.ShieldArmor(), minDamage)
+		if dealt <= shield {
+			return health, shield - dealt
+		}
+		// Shields are broken, the rest goes to health and it is reduced by health armor
+		overflow := dealt - shield
+		return health - math.Max(overflow-target.Armor(), 0), 0
+	}
+	return health - math.Max(damage-target.Armor(), minDamage), 0
+}
+
+// Real damage that one shot (all attacks of the weapon) deals to the target in its current state
+func (u *Unit) DamagePerShot(target *Unit) float64 {
+	weapon := u.WeaponAgainst(target)
+	if weapon == nil {
+		return 0
+	}
+	damage := u.AttackDamage(target)
+	health := float64(target.Health)
+	shield := float64(target.Shield)
+	if health+shield == 0 {
+		// Snapshots and units in fog have no hits info, so count vs full unit
+		health = float64(target.HealthMax)
+		shield = float64(target.ShieldMax)
+	}
+	h, s := health, shield
+	for x := uint32(0); x < weapon.Attacks; x++ {
+		h, s = applyAttack(target, damage, h, s)
+	}
+	return health + shield - h - s
+}
+
+// Real DPS vs target. Takes armor, shields, bonuses and upgrades into account
+func (u *Unit) DPSAgainst(target *Unit) float64 {
+	weapon := u.WeaponAgainst(target)
+	if weapon == nil || weapon.Speed == 0 {
+		return 0
+	}
+	return u.DamagePerShot(target) / float64(weapon.Speed)
+}
+
+// How many shots attacker needs to kill the target from its current hits. Returns math.MaxInt32 if it can't
+func (u *Unit) ShotsToKill(target *Unit) int {
+	weapon := u.WeaponAgainst(target)
+	if weapon == nil || weapon.Attacks == 0 {
+		return math.MaxInt32
+	}
+	damage := u.AttackDamage(target)
+	health := float64(target.Health)
+	shield := float64(target.Shield)
+	if health+shield == 0 {
+		health = float64(target.HealthMax)
+		shield = float64(target.ShieldMax)
+	}
+	// Each attack deals at least minDamage, so this loop is finite. Limit is here just in case
+	for shots := 1; shots < 10000; shots++ {
+		for x := uint32(0); x < weapon.Attacks; x++ {
+			health, shield = applyAttack(target, damage, health, shield)
+			if health <= 0 {
+				return shots
+			}
+		}
+	}
+	return math.MaxInt32
+}
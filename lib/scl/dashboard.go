@@ -0,0 +1,376 @@
+package scl
+
+import (
+	log "bitbucket.org/aisee/minilog"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const dashboardInterval = 250 * time.Millisecond // Frames are sent not more often than this
+
+// Live 2D view of the map in the browser. Frames are built in DebugSend and pushed to pages by server-sent events.
+// Ex: B.Dashboard = B.StartDashboard("localhost:8080") and open http://localhost:8080
+type Dashboard struct {
+	Addr     string
+	Interval time.Duration
+	server   *http.Server
+	clients  map[chan []byte]bool
+	paths    []dashboardPath
+	lastSent time.Time
+	mutex    sync.Mutex
+}
+
+type dashboardPath struct {
+	Color string       `json:"c"`
+	Ps    [][2]float64 `json:"ps"`
+}
+
+type dashboardUnit struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	R        float32 `json:"r"`
+	Alliance int32   `json:"a"`
+	Name     string  `json:"n"`
+}
+
+type dashboardCircle struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	R float64 `json:"r"`
+}
+
+type dashboardBase struct {
+	X     float64   `json:"x"`
+	Y     float64   `json:"y"`
+	Owner BaseOwner `json:"o"`
+}
+
+type dashboardFrame struct {
+	Loop     int               `json:"loop"`
+	Width    int               `json:"w"`
+	Height   int               `json:"h"`
+	Grids    map[string]string `json:"grids"` // Name -> base64 of one byte per cell, 0-255
+	Units    []dashboardUnit   `json:"units"` // All known units
+	Paths    []dashboardPath   `json:"paths"` // Paths added since the previous frame
+	Clusters []dashboardCircle `json:"clust"` // Circles around clusters of enemies
+	Ramps    [][2]float64      `json:"ramps"` // Ramp tops
+	Bases    []dashboardBase   `json:"bases"` // Expansions with owners
+}
+
+func (b *Bot) StartDashboard(addr string) *Dashboard {
+	d := &Dashboard{Addr: addr, Interval: dashboardInterval, clients: map[chan []byte]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.servePage)
+	mux.HandleFunc("/events", d.serveEvents)
+	d.server = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := d.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error(err)
+		}
+	}()
+	return d
+}
+
+func (d *Dashboard) Stop() {
+	if err := d.server.Close(); err != nil {
+		log.Error(err)
+	}
+}
+
+// Show path on the next sent frame
+func (d *Dashboard) AddPath(path point.Points, color api.Color) {
+	dp := dashboardPath{Color: fmt.Sprintf("rgb(%d,%d,%d)", color.R, color.G, color.B)}
+	for _, p := range path {
+		dp.Ps = append(dp.Ps, [2]float64{p.X(), p.Y()})
+	}
+	d.mutex.Lock()
+	d.paths = append(d.paths, dp)
+	d.mutex.Unlock()
+}
+
+func (d *Dashboard) servePage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(dashboardPage))
+}
+
+func (d *Dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprint(w, ": connected\n\n") // Sends headers so the page knows that stream is open
+	flusher.Flush()
+	ch := make(chan []byte, 1)
+	d.mutex.Lock()
+	d.clients[ch] = true
+	d.mutex.Unlock()
+	defer func() {
+		d.mutex.Lock()
+		delete(d.clients, ch)
+		d.mutex.Unlock()
+	}()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// One byte per cell for the whole map
+func gridLayer(w, h int, f func(x, y int) byte) string {
+	data := make([]byte, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			data[x+y*w] = f(x, y)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func influenceLayer(im *InfluenceMap) string {
+	max := 0.0
+	for _, v := range im.Data {
+		if v > max {
+			max = v
+		}
+	}
+	return gridLayer(im.Width, im.Height, func(x, y int) byte {
+		if max == 0 {
+			return 0
+		}
+		return byte(im.Data[x+y*im.Width] / max * 255)
+	})
+}
+
+func boolByte(ok bool) byte {
+	if ok {
+		return 255
+	}
+	return 0
+}
+
+func (b *Bot) dashboardFrame(paths []dashboardPath) *dashboardFrame {
+	w, h := b.Grid.PathingSizeX, b.Grid.PathingSizeY
+	f := &dashboardFrame{Loop: b.Loop, Width: w, Height: h, Paths: paths, Grids: map[string]string{}}
+	f.Grids["pathing"] = gridLayer(w, h, func(x, y int) byte { return boolByte(b.Grid.IsPathableFast(x, y)) })
+	f.Grids["buildable"] = gridLayer(w, h, func(x, y int) byte {
+		return boolByte(b.Grid.IsBuildable(point.Pt(float64(x), float64(y))))
+	})
+	if b.Influence.GroundThreat != nil {
+		f.Grids["ground threat"] = influenceLayer(b.Influence.GroundThreat)
+		f.Grids["air threat"] = influenceLayer(b.Influence.AirThreat)
+	}
+
+	everything := append(Units{}, b.Units.MyAll...)
+	everything.Add(b.Enemies.All...)
+	everything.Add(b.Units.Minerals.All()...)
+	everything.Add(b.Units.Geysers.All()...)
+	everything.Add(b.Units.Neutral.All()...)
+	for _, u := range everything {
+		f.Units = append(f.Units, dashboardUnit{X: u.Point().X(), Y: u.Point().Y(), R: u.Radius,
+			Alliance: int32(u.Alliance), Name: b.typeName(u.UnitType)})
+	}
+	for _, c := range b.Enemies.Clusters {
+		if len(c.Units) == 0 {
+			continue
+		}
+		var us Units
+		for u := range c.Units {
+			us.Add(u)
+		}
+		center := us.Center()
+		radius := 0.0
+		for _, u := range us {
+			radius = math.Max(radius, center.Dist(u)+float64(u.Radius))
+		}
+		f.Clusters = append(f.Clusters, dashboardCircle{X: center.X(), Y: center.Y(), R: radius})
+	}
+	for _, r := range b.Ramps.All {
+		f.Ramps = append(f.Ramps, [2]float64{r.Top.X(), r.Top.Y()})
+	}
+	for _, base := range b.Bases {
+		f.Bases = append(f.Bases, dashboardBase{X: base.Location.X(), Y: base.Location.Y(), Owner: base.Owner})
+	}
+	return f
+}
+
+// Send the current state to all pages. It is called from DebugSend
+func (d *Dashboard) Step() {
+	d.mutex.Lock()
+	if len(d.clients) == 0 {
+		d.paths = nil // Nobody would see them
+		d.mutex.Unlock()
+		return
+	}
+	if time.Since(d.lastSent) < d.Interval {
+		d.mutex.Unlock()
+		return // Paths are kept for the next frame
+	}
+	paths := d.paths
+	d.paths = nil
+	d.lastSent = time.Now()
+	d.mutex.Unlock()
+
+	data, err := json.Marshal(B.dashboardFrame(paths))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for ch := range d.clients {
+		select {
+		case ch <- data:
+		default: // Page is too slow, it will get the next frame
+		}
+	}
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>s2l dashboard</title>
+<style>
+body { background: #222; color: #ddd; font: 13px sans-serif; margin: 0; display: flex; }
+#side { padding: 8px; min-width: 160px; }
+#side label { display: block; margin: 2px 0; }
+canvas { image-rendering: pixelated; background: #000; }
+</style>
+</head>
+<body>
+<div id="side"><div id="loop">waiting...</div><div id="layers"></div></div>
+<canvas id="map"></canvas>
+<script>
+const layers = {"pathing": true, "buildable": false, "ground threat": false, "air threat": false,
+	"units": true, "paths": true, "clusters": true, "ramps": true, "bases": true};
+const side = document.getElementById("layers");
+for (const name in layers) {
+	const label = document.createElement("label");
+	const box = document.createElement("input");
+	box.type = "checkbox";
+	box.checked = layers[name];
+	box.onchange = () => { layers[name] = box.checked; draw(); };
+	label.appendChild(box);
+	label.appendChild(document.createTextNode(" " + name));
+	side.appendChild(label);
+}
+const canvas = document.getElementById("map");
+const ctx = canvas.getContext("2d");
+const gridColors = {"pathing": [80, 80, 80], "buildable": [40, 120, 40],
+	"ground threat": [255, 40, 40], "air threat": [60, 120, 255]};
+const allianceColors = {1: "#2f2", 2: "#2af", 3: "#aaa", 4: "#f33"};
+const ownerColors = ["#fff", "#2f2", "#f33", "#fa0"];
+let frame = null;
+let scale = 4;
+
+function decode(s) {
+	const bin = atob(s);
+	const data = new Uint8Array(bin.length);
+	for (let i = 0; i < bin.length; i++) data[i] = bin.charCodeAt(i);
+	return data;
+}
+
+// Game Y axis goes up, canvas Y goes down
+function cx(x) { return x * scale; }
+function cy(y) { return (frame.h - y) * scale; }
+
+function draw() {
+	if (!frame) return;
+	scale = Math.max(1, Math.floor(Math.min((window.innerWidth - 180) / frame.w, window.innerHeight / frame.h)));
+	canvas.width = frame.w * scale;
+	canvas.height = frame.h * scale;
+	const img = ctx.createImageData(frame.w, frame.h);
+	for (const name in gridColors) {
+		if (!layers[name] || !frame.grids[name]) continue;
+		const data = decode(frame.grids[name]);
+		const c = gridColors[name];
+		for (let y = 0; y < frame.h; y++) {
+			for (let x = 0; x < frame.w; x++) {
+				const v = data[x + y * frame.w] / 255;
+				if (v == 0) continue;
+				const addr = (x + (frame.h - 1 - y) * frame.w) * 4;
+				for (let n = 0; n < 3; n++) img.data[addr + n] = Math.min(255, img.data[addr + n] * (1 - v) + c[n] * v);
+				img.data[addr + 3] = 255;
+			}
+		}
+	}
+	const tmp = document.createElement("canvas");
+	tmp.width = frame.w;
+	tmp.height = frame.h;
+	tmp.getContext("2d").putImageData(img, 0, 0);
+	ctx.imageSmoothingEnabled = false;
+	ctx.drawImage(tmp, 0, 0, canvas.width, canvas.height);
+
+	if (layers.ramps) {
+		ctx.fillStyle = "#fff";
+		for (const r of frame.ramps || []) ctx.fillRect(cx(r[0]), cy(r[1] + 1), scale, scale);
+	}
+	if (layers.bases) {
+		for (const b of frame.bases || []) {
+			ctx.strokeStyle = ownerColors[b.o];
+			ctx.strokeRect(cx(b.x - 2.5), cy(b.y + 2.5), 5 * scale, 5 * scale);
+		}
+	}
+	if (layers.clusters) {
+		ctx.strokeStyle = "#f80";
+		for (const c of frame.clust || []) {
+			ctx.beginPath();
+			ctx.arc(cx(c.x), cy(c.y), c.r * scale, 0, 2 * Math.PI);
+			ctx.stroke();
+		}
+	}
+	if (layers.paths) {
+		for (const p of frame.paths || []) {
+			ctx.strokeStyle = p.c;
+			ctx.beginPath();
+			for (const pt of p.ps || []) ctx.lineTo(cx(pt[0] + 0.5), cy(pt[1] + 0.5));
+			ctx.stroke();
+		}
+	}
+	if (layers.units) {
+		for (const u of frame.units || []) {
+			ctx.fillStyle = allianceColors[u.a] || "#f0f";
+			ctx.beginPath();
+			ctx.arc(cx(u.x), cy(u.y), Math.max(1, u.r * scale), 0, 2 * Math.PI);
+			ctx.fill();
+		}
+	}
+}
+
+canvas.onmousemove = (e) => {
+	if (!frame) return;
+	const x = e.offsetX / scale, y = frame.h - e.offsetY / scale;
+	let text = "loop " + frame.loop + " (" + x.toFixed(1) + ", " + y.toFixed(1) + ")";
+	for (const u of frame.units || []) {
+		if (Math.hypot(u.x - x, u.y - y) <= Math.max(u.r, 0.5)) text += " " + u.n;
+	}
+	document.getElementById("loop").textContent = text;
+};
+window.onresize = draw;
+new EventSource("events").onmessage = (e) => {
+	frame = JSON.parse(e.data);
+	document.getElementById("loop").textContent = "loop " + frame.loop;
+	draw();
+};
+</script>
+</body>
+</html>
+`
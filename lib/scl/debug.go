@@ -15,6 +15,12 @@ var Blue = api.Color{R: 12, G: 51, B: 232}
 var White = api.Color{R: 255, G: 255, B: 255}
 
 func (b *Bot) DebugSend() {
+	if b.Dashboard != nil {
+		b.Dashboard.Step()
+	}
+	if texts := b.annotationTexts(); len(texts) > 0 {
+		b.DebugAddTexts(texts)
+	}
 	if len(b.DebugCommands) > 0 {
 		if err := b.Client.Debug(api.RequestDebug{
 			Debug: b.DebugCommands,
@@ -50,6 +56,29 @@ func (b *Bot) DebugAddLines(lines []*api.DebugLine) {
 				Lines: lines}}})
 }
 
+func (b *Bot) DebugAddTexts(texts []*api.DebugText) {
+	b.DebugAdd(&api.DebugCommand{
+		Command: &api.DebugCommand_Draw{
+			Draw: &api.DebugDraw{
+				Text: texts}}})
+}
+
+// Text in the world, slightly above the ground
+func (b *Bot) DebugText(text string, ptr point.Pointer, color api.Color) {
+	p := ptr.Point().To3D()
+	p.Z = float32(b.Grid.HeightAt(ptr) + 0.5)
+	b.DebugAddTexts([]*api.DebugText{{Color: &color, Text: text, WorldPos: p}})
+}
+
+// Text on the screen. Coordinates are from 0 to 1, top left corner is 0, 0
+func (b *Bot) DebugScreenText(text string, x, y float64, color api.Color) {
+	b.DebugAddTexts([]*api.DebugText{{
+		Color:      &color,
+		Text:       text,
+		VirtualPos: &api.Point{X: float32(x), Y: float32(y)},
+	}})
+}
+
 func (b *Bot) DebugAddUnits(unitType api.UnitTypeID, owner api.PlayerID, pos point.Point, qty uint32) {
 	b.DebugAdd(&api.DebugCommand{
 		Command: &api.DebugCommand_CreateUnit{
@@ -164,6 +193,9 @@ func (b *Bot) DebugEnemyUnits() {
 }
 
 func (b *Bot) DebugPath(path point.Points, color api.Color) {
+	if b.Dashboard != nil {
+		b.Dashboard.AddPath(path, color)
+	}
 	var boxes []*api.DebugBox
 	for _, p := range path {
 		z := b.Grid.HeightAt(p)
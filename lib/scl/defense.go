@@ -0,0 +1,236 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"math"
+)
+
+const workerDefenseRadius = ResourceSpreadDistance + 3 // Enemies closer than this to the townhall are attacking the base
+const workerDefenseRatio = 1.5                         // Defenders should be this much stronger than attackers
+const workerDefenseMaxShare = 0.8                      // Evacuate if more than this share of base workers is needed
+const workerEscapeHits = 0.35                          // Defender with less hits (part of max) escapes by mineral walk
+const workerSafeLoops = 112                            // Evacuated base is safe if there were no threats for 5 sec
+const workerMovingDelta = 0.1                          // Target that moved less than this since the last loop stands
+
+type baseDefense struct {
+	defenders  Tags
+	evacuated  Tags
+	threatLoop int // Last loop when threats were seen
+}
+
+// Coordinated defense of mining bases from worker rushes and harass
+type WorkerDefense struct {
+	Bases map[api.UnitTag]*baseDefense // Townhall -> its defense state
+	busy  TagsMap
+}
+
+func NewWorkerDefense() *WorkerDefense {
+	return &WorkerDefense{Bases: map[api.UnitTag]*baseDefense{}}
+}
+
+// Lanchester-like strength of the group against the enemies: total hits multiplied by total DPS
+func workersStrength(us, enemies Units) float64 {
+	hits, dps := 0.0, 0.0
+	for _, u := range us {
+		hits += u.Hits
+		best := 0.0
+		for _, e := range enemies {
+			best = math.Max(best, u.DPSAgainst(e))
+		}
+		dps += best
+	}
+	return hits * dps
+}
+
+// How many workers like this one are needed to beat the enemies. Returns -1 if workers can't fight them
+func workersNeeded(worker *Unit, threats Units) int {
+	one := workersStrength(Units{worker}, threats)
+	if one == 0 {
+		return -1
+	}
+	hits, dps := 0.0, 0.0
+	for _, e := range threats {
+		hits += e.Hits
+		dps += e.DPSAgainst(worker)
+	}
+	// Strength grows as square of the number of units
+	return int(math.Ceil(math.Sqrt(workerDefenseRatio * hits * dps / one)))
+}
+
+// Mineral at the townhall that is the furthest from the threats. Smart on mineral lets worker pass through units
+func escapeMineral(cc *Unit, threats Units) *Unit {
+	mfs := B.Units.Minerals.All().CloserThan(ResourceSpreadDistance, cc)
+	if mfs.Empty() || threats.Empty() {
+		return mfs.First()
+	}
+	center := threats.Center()
+	return mfs.FurthestTo(center)
+}
+
+func (wd *WorkerDefense) state(cc *Unit) *baseDefense {
+	bd := wd.Bases[cc.Tag]
+	if bd == nil {
+		bd = &baseDefense{}
+		wd.Bases[cc.Tag] = bd
+	}
+	return bd
+}
+
+// Worker goes back to its resource
+func returnToMining(worker *Unit) {
+	if mfTag := B.Miners.MineralForMiner[worker.Tag]; mfTag != 0 {
+		worker.CommandTag(ability.Smart, mfTag)
+	} else if gasTag := B.Miners.GasForMiner[worker.Tag]; gasTag != 0 {
+		worker.CommandTag(ability.Smart, gasTag)
+	} else {
+		worker.Command(ability.Stop_Stop)
+	}
+}
+
+func (wd *WorkerDefense) release(bd *baseDefense, miners Units) {
+	for _, worker := range miners.ByTags(bd.defenders) {
+		returnToMining(worker)
+	}
+	for _, worker := range miners.ByTags(bd.evacuated) {
+		// Workers were moved to other base, HandleMiners will assign them again
+		delete(B.Miners.CCForMiner, worker.Tag)
+		delete(B.Miners.MineralForMiner, worker.Tag)
+		delete(B.Miners.GasForMiner, worker.Tag)
+		worker.Command(ability.Stop_Stop)
+	}
+	bd.defenders = nil
+	bd.evacuated = nil
+}
+
+// Fight with the threats. Weak defenders escape by mineral walk and are replaced
+func (wd *WorkerDefense) defend(cc *Unit, bd *baseDefense, local, threats Units, needed int) {
+	defenders := local.ByTags(bd.defenders)
+	escape := escapeMineral(cc, threats)
+	var fighting Units
+	for _, d := range defenders {
+		if d.Hits < d.HitsMax*workerEscapeHits && threats.CanAttack(d, 1).Exists() && escape != nil {
+			d.CommandTag(ability.Smart, escape.Tag)
+			continue
+		}
+		fighting.Add(d)
+	}
+	// Pull healthy workers closest to the threats
+	center := threats.Center()
+	candidates := local.Filter(func(u *Unit) bool {
+		return fighting.ByTag(u.Tag) == nil && u.Hits >= u.HitsMax*workerEscapeHits
+	})
+	candidates.OrderByDistanceTo(center, false)
+	for _, c := range candidates {
+		if fighting.Len() >= needed {
+			break
+		}
+		fighting.Add(c)
+	}
+	// Release extra defenders
+	if fighting.Len() > needed {
+		for _, d := range fighting[needed:] {
+			returnToMining(d)
+		}
+		fighting = fighting[:needed]
+	}
+	bd.defenders = Tags(fighting.Tags())
+	wd.markBusy(defenders)
+	wd.markBusy(fighting)
+
+	fa := fighting.AllocateTargets(1, threats)
+	for _, d := range fighting {
+		target := fa[d.Tag]
+		if target == nil {
+			target = threats.ClosestTo(d)
+		}
+		// Enemy units have no orders, so movement is known only from the position change
+		if d.InRange(target, 0.5) || target.PosDelta.Len() < workerMovingDelta {
+			d.CommandTag(ability.Attack_Attack, target.Tag)
+			continue
+		}
+		// Surround: cut off the moving target where it will be when the defender gets there
+		frames := int(d.FramesToPos(target)) + B.FramesPerOrder
+		d.CommandPos(ability.Move_Move, target.EstimatePositionAfter(frames))
+	}
+}
+
+// Move all workers of the base to the other townhall by mineral walk. Returns false if there is no safe townhall
+func (wd *WorkerDefense) evacuate(bd *baseDefense, local, threats, ccs Units, from *Unit) bool {
+	var to *Unit
+	for _, cc := range ccs {
+		if cc.Tag != from.Tag && threats.CloserThan(workerDefenseRadius, cc).Empty() &&
+			(to == nil || cc.Dist2(from) < to.Dist2(from)) {
+			to = cc
+		}
+	}
+	if to == nil {
+		return false
+	}
+	escape := escapeMineral(to, nil)
+	if escape == nil {
+		return false
+	}
+	for _, worker := range local {
+		if worker.TargetTag() != escape.Tag {
+			worker.CommandTag(ability.Smart, escape.Tag)
+		}
+	}
+	bd.evacuated = Tags(local.Tags())
+	bd.defenders = nil
+	wd.markBusy(local)
+	return true
+}
+
+func (wd *WorkerDefense) markBusy(us Units) {
+	for _, u := range us {
+		wd.busy[u.Tag] = true
+	}
+}
+
+// Defend bases and return miners that are free for mining. Call it before HandleMiners or WorkerAllocator.Step
+func (wd *WorkerDefense) Step(miners, ccs, enemies Units) Units {
+	wd.busy = TagsMap{}
+	for _, cc := range ccs {
+		bd := wd.state(cc)
+		threats := enemies.CloserThan(workerDefenseRadius, cc).Filter(Visible, func(u *Unit) bool {
+			return !u.IsStructure() && (u.GroundDPS() > 0 || u.IsWorker())
+		})
+		if threats.Len() == 1 && threats[0].IsWorker() {
+			threats = nil // Scout
+		}
+		if threats.Empty() {
+			if bd.threatLoop+workerSafeLoops < B.Loop || bd.evacuated.Empty() {
+				wd.release(bd, miners)
+			} else {
+				wd.markBusy(miners.ByTags(bd.evacuated)) // Wait a little before going back
+			}
+			continue
+		}
+		bd.threatLoop = B.Loop
+		if bd.evacuated.Exists() {
+			wd.markBusy(miners.ByTags(bd.evacuated))
+			continue
+		}
+		local := miners.Filter(func(u *Unit) bool {
+			return B.Miners.CCForMiner[u.Tag] == cc.Tag || u.IsCloserThan(workerDefenseRadius, cc)
+		})
+		if local.Empty() {
+			continue
+		}
+		needed := workersNeeded(local[0], threats)
+		if needed < 0 || float64(needed) > float64(local.Len())*workerDefenseMaxShare {
+			if wd.evacuate(bd, local, threats, ccs, cc) || needed < 0 {
+				continue
+			}
+			needed = local.Len() // Nowhere to run, everyone fights
+		}
+		wd.defend(cc, bd, local, threats, needed)
+	}
+	for tag := range wd.Bases {
+		if ccs.ByTag(tag) == nil {
+			delete(wd.Bases, tag)
+		}
+	}
+	return miners.Filter(func(u *Unit) bool { return !wd.busy[u.Tag] })
+}
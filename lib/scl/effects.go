@@ -0,0 +1,118 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/effect"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"math"
+)
+
+const effectZoneGap = 0.5         // Additional distance to keep from effects
+const effectMinRemainingLoops = 4 // Effects that will disappear sooner are ignored by pathing
+
+type EffectZone struct {
+	point.Circle
+	Until int // Loop when effect disappears. 0 - unknown
+}
+
+type effectDanger struct {
+	Loops int  // Effect duration. 0 - while it exists
+	Own   bool // Own effect is also dangerous for us
+}
+
+// Ground effects that should be avoided by pathing
+var DangerousEffects = map[api.EffectID]effectDanger{
+	effect.PsiStorm:                   {64, true}, // 2.85 sec
+	effect.CorrosiveBile:              {49, true}, // Time between cast and landing
+	effect.LiberatorDefenderZoneSetup: {0, false},
+	effect.LiberatorDefenderZone:      {0, false},
+	effect.BlindingCloud:              {0, false},
+}
+
+// Units that act like effects: radius and time until explosion
+var DangerousEffectUnits = map[api.UnitTypeID]struct {
+	Radius float64
+	effectDanger
+}{
+	protoss.DisruptorPhased: {1.5, effectDanger{47, true}}, // 2.1 sec
+	terran.KD8Charge:        {KD8Radius, effectDanger{22, false}},
+}
+
+type effectKey struct {
+	id  api.EffectID
+	tag api.UnitTag // For units. Disruptor shots are moving, so position can't be used
+	pos point.Point
+}
+
+// Collect zones of dangerous effects. Time of the first appearance is remembered to calculate remaining duration
+func (b *Bot) UpdateEffectZones() {
+	if b.effectsSeen == nil {
+		b.effectsSeen = map[effectKey]int{}
+	}
+	seen := map[effectKey]int{}
+	var zones []EffectZone
+	addZone := func(key effectKey, pos point.Point, radius float64, danger effectDanger) {
+		loop, ok := b.effectsSeen[key]
+		if !ok {
+			loop = b.Loop
+		}
+		seen[key] = loop
+		until := 0
+		if danger.Loops > 0 {
+			until = loop + danger.Loops
+			if until-b.Loop < effectMinRemainingLoops {
+				return
+			}
+		}
+		zones = append(zones, EffectZone{Circle: point.Circle{Point: pos, R: radius}, Until: until})
+	}
+
+	// RecentEffects also contain biles that already disappeared from observation
+	for _, effects := range append(b.RecentEffects, b.Obs.RawData.Effects) {
+		for _, e := range effects {
+			danger, ok := DangerousEffects[e.EffectId]
+			if !ok || (e.Alliance == api.Alliance_Self && !danger.Own) {
+				continue
+			}
+			radius := float64(e.Radius)
+			if radius == 0 && int(e.EffectId) < len(b.U.Effects) {
+				radius = float64(b.U.Effects[e.EffectId].Radius)
+			}
+			for _, p := range e.Pos {
+				key := effectKey{id: e.EffectId, pos: point.Pt2(p)}
+				if _, ok := seen[key]; !ok {
+					addZone(key, key.pos, radius, danger)
+				}
+			}
+		}
+	}
+	for uType, danger := range DangerousEffectUnits {
+		units := append(Units{}, b.Units.Enemy[uType]...)
+		if danger.Own {
+			units.Add(b.Units.My[uType]...)
+		}
+		for _, u := range units {
+			addZone(effectKey{tag: u.Tag}, u.Point(), danger.Radius, danger.effectDanger)
+		}
+	}
+	b.effectsSeen = seen
+	b.EffectZones = zones
+}
+
+// Mark cells covered by effect zones as not pathable
+func MarkEffectZones(g *grid.Grid, zones []EffectZone) {
+	for _, z := range zones {
+		r := z.R + effectZoneGap
+		for y := math.Floor(z.Y() - r); y <= z.Y()+r; y++ {
+			for x := math.Floor(z.X() - r); x <= z.X()+r; x++ {
+				p := point.Pt(x, y)
+				if p.CellCenter().IsCloserThan(r, z.Point) {
+					g.SetPathable(p, false)
+				}
+			}
+		}
+	}
+}
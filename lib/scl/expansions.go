@@ -9,13 +9,17 @@ import (
 // Taken from Chippydip's lib
 // Cluster breaks a list of units into clusters based on the given clustering distance.
 func MakeCluster(units Units, distance float64) []UnitCluster {
+	return ClusterUnits(units.Filter(func(unit *Unit) bool {
+		return unit.IsMineral() || unit.IsGeyser()
+	}), distance)
+}
+
+// Same as MakeCluster but for any units
+func ClusterUnits(units Units, distance float64) []UnitCluster {
 	maxDistance := distance * distance
 
 	var clusters []UnitCluster
 	for _, u := range units {
-		if !u.IsMineral() && !u.IsGeyser() {
-			continue
-		}
 		// Find the nearest cluster
 		minDist := math.MaxFloat64
 		clusterIndex := -1
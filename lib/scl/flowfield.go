@@ -0,0 +1,258 @@
+package scl
+
+import (
+	"container/heap"
+	"fmt"
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+)
+
+const flowFieldCacheSize = 16 // Max number of cached fields, the oldest ones are dropped
+
+// Direction field over the whole map. Each reachable cell points to the next cell on the shortest way
+// to the closest goal. It is the same as Steps made by FindPaths, but for many goals and with O(1) access
+type FlowField struct {
+	Goals  point.Points
+	Width  int
+	Height int
+	To     []int32   // Cell address -> address of the next cell, -1 if cell is unreachable. Goals point to themselves
+	Dist   []float32 // Cell address -> distance to the closest goal
+	used   int       // Loop when field was used last time
+}
+
+type flowItem struct {
+	addr int32
+	dist float32
+}
+type flowQueue []flowItem
+
+func (q flowQueue) Len() int            { return len(q) }
+func (q flowQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q flowQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *flowQueue) Push(x interface{}) { *q = append(*q, x.(flowItem)) }
+func (q *flowQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Calculate field for the grid. Reaper fields use cliff jumps like FindPaths does
+func NewFlowField(grid *grid.Grid, reaper bool, goals ...point.Point) *FlowField {
+	ff := &FlowField{Width: grid.PathingSizeX, Height: grid.PathingSizeY}
+	size := ff.Width * ff.Height
+	ff.To = make([]int32, size)
+	ff.Dist = make([]float32, size)
+	for n := range ff.To {
+		ff.To[n] = -1
+		ff.Dist[n] = math.MaxFloat32
+	}
+	q := &flowQueue{}
+	for _, goal := range goals {
+		g := goal.Floor()
+		if !grid.IsPathable(g) {
+			g = B.FindClosestPathable(grid, g) // Ex: goal is a building
+		}
+		addr := ff.addr(g)
+		if addr == -1 || !grid.IsPathable(g) {
+			continue
+		}
+		ff.Goals.Add(g)
+		ff.To[addr] = int32(addr)
+		ff.Dist[addr] = 0
+		heap.Push(q, flowItem{int32(addr), 0})
+	}
+	for q.Len() > 0 {
+		item := heap.Pop(q).(flowItem)
+		if item.dist > ff.Dist[item.addr] {
+			continue // Outdated queue item
+		}
+		src := ff.point(int(item.addr))
+		for n, p := range src.Neighbours8(1) {
+			distMul := float32(1)
+			if !grid.IsPathable(p) {
+				if !reaper || grid.IsBuildable(p) {
+					continue
+				}
+				p += p - src // Shift 1 cell further
+				if !grid.IsBuildable(p) || !grid.IsPathable(p) || grid.HeightAt(src) == grid.HeightAt(p) {
+					continue
+				}
+				distMul = 2 // Reaper can jump here
+			} else if n >= 4 && !(grid.IsPathable(point.Pt(p.X(), src.Y())) && grid.IsPathable(point.Pt(src.X(), p.Y()))) {
+				continue // Don't cut corners of obstacles like Pathfinder
+			}
+			addr := ff.addr(p)
+			if addr == -1 {
+				continue
+			}
+			d := item.dist + distMul
+			if n >= 4 {
+				d = item.dist + distMul*math.Sqrt2 // Diagonal
+			}
+			if d < ff.Dist[addr] {
+				ff.Dist[addr] = d
+				ff.To[addr] = item.addr
+				heap.Push(q, flowItem{int32(addr), d})
+			}
+		}
+	}
+	return ff
+}
+
+func (ff *FlowField) addr(p point.Point) int {
+	x, y := int(p.X()), int(p.Y())
+	if p.X() < 0 || p.Y() < 0 || x >= ff.Width || y >= ff.Height {
+		return -1
+	}
+	return x + y*ff.Width
+}
+
+func (ff *FlowField) point(addr int) point.Point {
+	return point.Pt(float64(addr%ff.Width), float64(addr/ff.Width))
+}
+
+// Next cell on the way to the closest goal. Returns 0 if goals are unreachable from this point
+func (ff *FlowField) Next(ptr point.Pointer) point.Point {
+	addr := ff.addr(ptr.Point().Floor())
+	if addr == -1 || ff.To[addr] == -1 {
+		return 0
+	}
+	return ff.point(int(ff.To[addr]))
+}
+
+// Unit vector to the next cell. Returns 0 on the goal or if goals are unreachable
+func (ff *FlowField) Dir(ptr point.Pointer) point.Point {
+	p := ptr.Point().Floor()
+	next := ff.Next(p)
+	if next == 0 || next == p {
+		return 0
+	}
+	return (next - p).Norm()
+}
+
+// Ground distance to the closest goal. Returns -1 if goals are unreachable
+func (ff *FlowField) Distance(ptr point.Pointer) float64 {
+	addr := ff.addr(ptr.Point().Floor())
+	if addr == -1 || ff.To[addr] == -1 {
+		return -1
+	}
+	return float64(ff.Dist[addr])
+}
+
+// Point that is the given number of steps ahead. Returns 0 if goals are unreachable
+func (ff *FlowField) Follow(ptr point.Pointer, steps int) point.Point {
+	addr := ff.addr(ptr.Point().Floor())
+	if addr == -1 || ff.To[addr] == -1 {
+		return 0
+	}
+	for x := 0; x < steps; x++ {
+		addr = int(ff.To[addr])
+	}
+	return ff.point(addr)
+}
+
+// Convert field into Steps. Distance of each cell is the distance to the goal like in FindPaths
+func (ff *FlowField) Steps() Steps {
+	steps := Steps{}
+	cells := make([]*Cell, len(ff.To))
+	cell := func(addr int) *Cell {
+		if cells[addr] == nil {
+			cells[addr] = &Cell{Point: ff.point(addr), Distance: float64(ff.Dist[addr])}
+		}
+		return cells[addr]
+	}
+	for addr, next := range ff.To {
+		if next != -1 {
+			steps[ff.point(addr)] = cell(int(next))
+		}
+	}
+	return steps
+}
+
+// Move units along the field. Each unit is sent to the point that is lookahead steps ahead
+func (us Units) CommandFlow(ff *FlowField, lookahead int) {
+	for _, u := range us {
+		if pos := ff.Follow(u, lookahead); pos != 0 {
+			u.CommandPos(ability.Move, pos.CellCenter())
+		}
+	}
+}
+
+// Fields for one grid cached by goals. Cache is cleared when pathing of the grid is changed
+type FlowFields struct {
+	Grid   func() *grid.Grid // Bot replaces its grids, so current one is requested every time
+	Reaper bool
+	fields map[string]*FlowField
+	hash   uint64
+	loop   int
+	mutex  sync.Mutex
+}
+
+func NewFlowFields(gridFunc func() *grid.Grid, reaper bool) *FlowFields {
+	return &FlowFields{Grid: gridFunc, Reaper: reaper, fields: map[string]*FlowField{}, loop: -1}
+}
+
+func flowKey(goals point.Points) string {
+	ps := make(point.Points, 0, goals.Len())
+	for _, g := range goals {
+		ps.Add(g.Floor())
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Y() != ps[j].Y() {
+			return ps[i].Y() < ps[j].Y()
+		}
+		return ps[i].X() < ps[j].X()
+	})
+	return fmt.Sprint(ps)
+}
+
+func gridHash(g *grid.Grid) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(g.StartRaw.PathingGrid.Data)
+	return h.Sum64()
+}
+
+// Drop all cached fields
+func (fc *FlowFields) Invalidate() {
+	fc.mutex.Lock()
+	fc.fields = map[string]*FlowField{}
+	fc.mutex.Unlock()
+}
+
+// Cached or new field for the goals. Grid changes are checked once per loop
+func (fc *FlowFields) Get(goals ...point.Point) *FlowField {
+	fc.mutex.Lock()
+	defer fc.mutex.Unlock()
+	g := fc.Grid()
+	if fc.loop != B.Loop {
+		fc.loop = B.Loop
+		if hash := gridHash(g); hash != fc.hash {
+			fc.hash = hash
+			fc.fields = map[string]*FlowField{}
+		}
+	}
+	key := flowKey(goals)
+	if ff, ok := fc.fields[key]; ok {
+		ff.used = B.Loop
+		return ff
+	}
+	if len(fc.fields) >= flowFieldCacheSize {
+		var oldest string
+		for k, ff := range fc.fields {
+			if oldest == "" || ff.used < fc.fields[oldest].used {
+				oldest = k
+			}
+		}
+		delete(fc.fields, oldest)
+	}
+	ff := NewFlowField(g, fc.Reaper, goals...)
+	ff.used = B.Loop
+	fc.fields[key] = ff
+	return ff
+}
@@ -0,0 +1,177 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"math"
+	"testing"
+)
+
+// Grid drawn by rows starting from y = 0: '.' - buildable low ground, '^' - buildable high ground,
+// '#' - obstacle (or cliff between levels). Rows should be 8 cells wide
+func testFlowGrid(rows ...string) *grid.Grid {
+	width, height := len(rows[0]), len(rows)
+	pathing := make([]byte, (width*height+7)/8)
+	placement := make([]byte, len(pathing))
+	heights := make([]byte, width*height)
+	for y, row := range rows {
+		for x, c := range row {
+			addr := x + y*width
+			heights[addr] = 127
+			if c == '#' {
+				continue
+			}
+			pathing[addr/8] |= 1 << (7 - addr%8)
+			placement[addr/8] |= 1 << (7 - addr%8)
+			if c == '^' {
+				heights[addr] = 127 + 16
+			}
+		}
+	}
+	size := &api.Size2DI{X: int32(width), Y: int32(height)}
+	return grid.New(&api.StartRaw{
+		MapSize:       size,
+		PathingGrid:   &api.ImageData{BitsPerPixel: 1, Size_: size, Data: pathing},
+		PlacementGrid: &api.ImageData{BitsPerPixel: 1, Size_: size, Data: placement},
+		TerrainHeight: &api.ImageData{BitsPerPixel: 8, Size_: size, Data: heights},
+	}, &api.MapState{})
+}
+
+func testFlowDistance(t *testing.T, ff *FlowField, p point.Point, expected float64) {
+	t.Helper()
+	if dist := ff.Distance(p); math.Abs(dist-expected) > 1e-5 {
+		t.Errorf("distance at %v: %v, expected %v", p, dist, expected)
+	}
+}
+
+func TestFlowField_Distances(t *testing.T) {
+	g := testFlowGrid(
+		"........",
+		"........",
+		"........",
+		"........",
+	)
+	ff := NewFlowField(g, false, point.Pt(0.5, 0.5))
+	testFlowDistance(t, ff, point.Pt(0, 0), 0)
+	testFlowDistance(t, ff, point.Pt(3, 0), 3)
+	testFlowDistance(t, ff, point.Pt(3, 3), 3*math.Sqrt2)
+	testFlowDistance(t, ff, point.Pt(5.7, 2.2), 2*math.Sqrt2+3)
+	if next := ff.Next(point.Pt(3, 3)); next != point.Pt(2, 2) {
+		t.Errorf("next of (3, 3): %v", next)
+	}
+	if dir := ff.Dir(point.Pt(3, 3)); dir.Dist(point.Pt(-1, -1).Norm()) > 1e-9 {
+		t.Errorf("direction at (3, 3): %v", dir)
+	}
+	if dir := ff.Dir(point.Pt(0, 0)); dir != 0 {
+		t.Errorf("direction on the goal: %v", dir)
+	}
+}
+
+func TestFlowField_WallCorner(t *testing.T) {
+	g := testFlowGrid(
+		"....#...",
+		"....#...",
+		"....#...",
+		"........",
+		"........",
+	)
+	ff := NewFlowField(g, false, point.Pt(6, 0))
+	// Corners of the wall are not cut: (3, 2) -> (3, 3) -> (4, 3) -> (5, 3) -> (6, 2) -> (6, 1) -> (6, 0)
+	if dir := ff.Dir(point.Pt(3, 2)); dir != point.Pt(0, 1) {
+		t.Errorf("direction at the wall corner: %v", dir)
+	}
+	if dir := ff.Dir(point.Pt(4, 3)); dir != point.Pt(1, 0) {
+		t.Errorf("direction at the wall corner: %v", dir)
+	}
+	testFlowDistance(t, ff, point.Pt(3, 2), 5+math.Sqrt2)
+	testFlowDistance(t, ff, point.Pt(2, 0), 6+2*math.Sqrt2)
+	for y := 0; y < ff.Height; y++ {
+		for x := 0; x < ff.Width; x++ {
+			p := point.Pt(float64(x), float64(y))
+			if !g.IsPathable(p) {
+				if ff.Next(p) != 0 || ff.Distance(p) != -1 {
+					t.Errorf("obstacle %v is in the field", p)
+				}
+				continue
+			}
+			next := ff.Next(p)
+			if !g.IsPathable(next) || next.Dist(p) > math.Sqrt2+1e-9 {
+				t.Errorf("bad next cell %v for %v", next, p)
+			}
+			if next != p && ff.Distance(next) >= ff.Distance(p) {
+				t.Errorf("distance doesn't decrease from %v to %v", p, next)
+			}
+		}
+	}
+}
+
+func TestFlowField_MultipleGoals(t *testing.T) {
+	g := testFlowGrid(
+		"........",
+		"....#...",
+		"........",
+		"...#....",
+	)
+	ff := NewFlowField(g, false, point.Pt(0, 1), point.Pt(7, 1))
+	if ff.Goals.Len() != 2 {
+		t.Fatalf("goals: %v", ff.Goals)
+	}
+	testFlowDistance(t, ff, point.Pt(7, 1), 0)
+	testFlowDistance(t, ff, point.Pt(2, 1), 2)
+	testFlowDistance(t, ff, point.Pt(5, 1), 2)
+	if next := ff.Next(point.Pt(2, 1)); next != point.Pt(1, 1) {
+		t.Errorf("next of (2, 1): %v", next)
+	}
+	if next := ff.Next(point.Pt(5, 1)); next != point.Pt(6, 1) {
+		t.Errorf("next of (5, 1): %v", next)
+	}
+	// The closer goal wins, the other one is ignored: (3, 1) -> (2, 1) is shorter than the way around the wall
+	testFlowDistance(t, ff, point.Pt(3, 1), 3)
+}
+
+func TestFlowField_Unreachable(t *testing.T) {
+	g := testFlowGrid(
+		"...#....",
+		"...#....",
+		"...#....",
+	)
+	ff := NewFlowField(g, false, point.Pt(0, 0))
+	p := point.Pt(5, 1)
+	if ff.Next(p) != 0 || ff.Dir(p) != 0 || ff.Distance(p) != -1 {
+		t.Errorf("unreachable cell: next %v, dir %v, distance %v", ff.Next(p), ff.Dir(p), ff.Distance(p))
+	}
+	if ff.Distance(point.Pt(-1, 0)) != -1 || ff.Next(point.Pt(8, 0)) != 0 {
+		t.Error("cells outside of the map are reachable")
+	}
+}
+
+func TestFlowField_Reaper(t *testing.T) {
+	g := testFlowGrid(
+		"........",
+		"........",
+		"........",
+		"####....",
+		"^^^^#...",
+		"^^^^#...",
+		"^^^^#...",
+		"^^^^#...",
+	)
+	goal := point.Pt(1, 6)
+	// High ground has no ramp, so it is unreachable for ground units. Diagonal gap at (3, 3)-(4, 4) is not a way
+	ground := NewFlowField(g, false, goal)
+	testFlowDistance(t, ground, point.Pt(1, 1), -1)
+
+	reaper := NewFlowField(g, true, goal)
+	// (1, 1) -> (1, 2), jump over the cliff to (1, 4) for the double cost, then (1, 5) -> (1, 6)
+	testFlowDistance(t, reaper, point.Pt(1, 1), 1+2+2)
+	if next := reaper.Next(point.Pt(1, 2)); next != point.Pt(1, 4) {
+		t.Errorf("reaper doesn't jump: next of (1, 2) is %v", next)
+	}
+	// (6, 6) -> (5, 6), jump over the wall between levels to (3, 6), then (2, 6) -> (1, 6)
+	testFlowDistance(t, reaper, point.Pt(6, 6), 1+2+2)
+
+	// Obstacles between cells of the same level are not jumped over
+	reaper = NewFlowField(testFlowGrid("..#....."), true, point.Pt(0, 0))
+	testFlowDistance(t, reaper, point.Pt(3, 0), -1)
+}
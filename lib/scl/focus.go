@@ -0,0 +1,189 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"math"
+	"sort"
+)
+
+// Planned targets for the squad: attacker -> target
+type FireAllocation map[api.UnitTag]*Unit
+
+// Expected damage that will be dealt to each target during this volley
+type expectedDamage map[api.UnitTag]float64
+
+// Hits per second that the unit lost during the last HitHistoryLoops
+func damageRate(u *Unit) float64 {
+	lost := 0
+	hits := B.U.HitsHistory[u.Tag]
+	for n := 0; n+1 < len(hits); n += 2 {
+		if hits[n] >= B.Loop-HitHistoryLoops {
+			lost += hits[n+1]
+		}
+	}
+	return float64(lost) * FPS / HitHistoryLoops
+}
+
+// Hits that target still has after expected damage from our units and others (by recent damage rate)
+func (ed expectedDamage) remaining(target *Unit) float64 {
+	hits := target.Hits
+	if hits == 0 {
+		hits = target.HitsMax // Snapshot
+	}
+	// Someone is already damaging the target. Count what it will lose until next order
+	hits -= damageRate(target) * float64(B.FramesPerOrder) / FPS
+	return hits - ed[target.Tag]
+}
+
+// Damage that attacker can deal to the target, but not more than target has
+func (ed expectedDamage) useful(attacker, target *Unit) float64 {
+	return math.Min(attacker.DamagePerShot(target), math.Max(ed.remaining(target), 0))
+}
+
+// Value of the target: threat that we remove per hit point that we need to deal
+func targetValue(target *Unit, hits float64) float64 {
+	threat := math.Max(target.GroundDPS(), target.AirDPS())
+	return (threat + 1) / math.Max(hits, 1)
+}
+
+func inGroups(target *Unit, targetsGroups []Units) bool {
+	for _, targets := range targetsGroups {
+		if targets.ByTag(target.Tag) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Distributes attackers over targets to minimize overkill and maximize kills per volley.
+// Groups are in priority from higher to lower like in Attack(). Gap is added to weapon range to allow attackers
+// to pick targets that are a little bit out of range
+func (us Units) AllocateTargets(gap float64, targetsGroups ...Units) FireAllocation {
+	fa := FireAllocation{}
+	ed := expectedDamage{}
+	free := Units{}
+	for _, u := range us {
+		if !u.IsArmed() {
+			continue
+		}
+		// Units that are already shooting one of the targets in range will continue to do so
+		if target := B.Enemies.All.ByTag(u.TargetTag()); target != nil && u.InRange(target, 0) &&
+			inGroups(target, targetsGroups) {
+			fa[u.Tag] = target
+			ed[target.Tag] += u.DamagePerShot(target)
+			continue
+		}
+		if u.IsCoolToAttack() {
+			free.Add(u)
+		}
+	}
+
+	for _, targets := range targetsGroups {
+		if free.Empty() {
+			break
+		}
+		targets = targets.Filter(Visible)
+		candidates := map[api.UnitTag]Units{} // target -> attackers that can hit it
+		flexibility := map[api.UnitTag]int{}  // attacker -> number of targets in range
+		for _, u := range free {
+			for _, target := range targets {
+				if u.WeaponAgainst(target) != nil && u.InRange(target, gap) {
+					candidates[target.Tag] = append(candidates[target.Tag], u)
+					flexibility[u.Tag]++
+				}
+			}
+		}
+
+		// First pass: kill what we can, starting with most valuable targets
+		targets = targets.Filter(func(unit *Unit) bool { return candidates[unit.Tag].Exists() })
+		targets.OrderBy(func(unit *Unit) float64 { return targetValue(unit, ed.remaining(unit)) }, true)
+		for _, target := range targets {
+			attackers := candidates[target.Tag].Filter(func(unit *Unit) bool { return fa[unit.Tag] == nil })
+			// Least flexible attackers go first, then strongest
+			sort.SliceStable(attackers, func(i, j int) bool {
+				fi, fj := flexibility[attackers[i].Tag], flexibility[attackers[j].Tag]
+				if fi != fj {
+					return fi < fj
+				}
+				return attackers[i].DamagePerShot(target) > attackers[j].DamagePerShot(target)
+			})
+			need := ed.remaining(target)
+			if need <= 0 {
+				continue // Already dead
+			}
+			var squad Units
+			sum := 0.0
+			for _, u := range attackers {
+				if sum >= need {
+					break
+				}
+				squad.Add(u)
+				sum += u.DamagePerShot(target)
+			}
+			if sum < need {
+				continue // Can't kill it in this volley, maybe in second pass
+			}
+			// Remove attackers that are not needed for the kill to reduce overkill
+			for n := squad.Len() - 1; n >= 0; n-- {
+				dps := squad[n].DamagePerShot(target)
+				if sum-dps >= need {
+					sum -= dps
+					squad = append(squad[:n], squad[n+1:]...)
+				}
+			}
+			for _, u := range squad {
+				fa[u.Tag] = target
+			}
+			ed[target.Tag] += sum
+		}
+
+		// Second pass: the rest shoot targets that will lose most, preferring almost dead ones
+		for _, u := range free {
+			if fa[u.Tag] != nil {
+				continue
+			}
+			var best *Unit
+			bestScore := 0.0
+			for _, target := range targets {
+				if ed.remaining(target) <= 0 || candidates[target.Tag].ByTag(u.Tag) == nil {
+					continue
+				}
+				score := ed.useful(u, target) * targetValue(target, ed.remaining(target))
+				if best == nil || score > bestScore {
+					best = target
+					bestScore = score
+				}
+			}
+			if best != nil {
+				fa[u.Tag] = best
+				ed[best.Tag] += ed.useful(u, best)
+			}
+		}
+		free = free.Filter(func(unit *Unit) bool { return fa[unit.Tag] == nil })
+	}
+	return fa
+}
+
+// AttackFunc that uses allocated target when the current targets group contains it.
+// Units without allocated target fall back to DefaultAttackFunc
+func (fa FireAllocation) AttackFunc(u *Unit, priority int, targets Units) bool {
+	target := fa[u.Tag]
+	if target == nil {
+		return DefaultAttackFunc(u, priority, targets)
+	}
+	if targets.ByTag(target.Tag) == nil {
+		return false // Target is in the other group
+	}
+	u.CommandTag(ability.Attack_Attack, target.Tag)
+	B.U.LastAttack[u.Tag] = B.Loop
+	return true
+}
+
+// Same as Attack(), but targets are distributed over the whole group
+func (us Units) AttackFocused(targetsGroups ...Units) {
+	fa := us.AllocateTargets(0, targetsGroups...)
+	for _, u := range us {
+		u.AttackCustom(fa.AttackFunc, DefaultMoveFunc, targetsGroups...)
+	}
+}
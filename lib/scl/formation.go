@@ -0,0 +1,201 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"math"
+	"sort"
+)
+
+type Formation int
+
+const (
+	FormationLine Formation = iota + 1
+	FormationConcave
+	FormationBox
+)
+
+const formationLookahead = 3   // How far ahead of the group center formation is placed while moving
+const formationMaxArc = 2.1    // Max concave arc angle (~120 degrees). Next row is added if there is no room
+const formationSpread = 2      // Group reforms if units are further than this from slots (in spacings)
+const formationMinConcaveR = 4 // Minimal concave radius
+
+type formationSlot struct {
+	point.Point
+	row int
+}
+
+// Distance between units in the formation
+func (us Units) formationSpacing() float64 {
+	maxRadius := 0.0
+	for _, u := range us {
+		maxRadius = math.Max(maxRadius, float64(u.Radius))
+	}
+	return maxRadius*2 + 0.5
+}
+
+func formationSlots(formation Formation, qty int, center, dir point.Point, spacing float64) []formationSlot {
+	lateral := dir * 1i
+	var slots []formationSlot
+	switch formation {
+	case FormationLine:
+		for n := 0; n < qty; n++ {
+			slots = append(slots, formationSlot{center + lateral.Mul((float64(n)-float64(qty-1)/2)*spacing), 0})
+		}
+	case FormationConcave:
+		r := math.Max(float64(qty)*spacing/formationMaxArc, formationMinConcaveR)
+		focus := center + dir.Mul(r) // Concave is facing this point
+		left := qty
+		for row := 0; left > 0; row++ {
+			rr := r + float64(row)*spacing
+			inRow := MinInt(left, int(formationMaxArc*rr/spacing)+1)
+			step := spacing / rr
+			for n := 0; n < inRow; n++ {
+				angle := (float64(n) - float64(inRow-1)/2) * step
+				slots = append(slots, formationSlot{focus - dir.Mul(rr).Rotate(angle), row})
+			}
+			left -= inRow
+		}
+	case FormationBox:
+		cols := int(math.Ceil(math.Sqrt(float64(qty))))
+		for n := 0; n < qty; n++ {
+			row, col := n/cols, n%cols
+			inRow := MinInt(cols, qty-row*cols)
+			offset := lateral.Mul((float64(col) - float64(inRow-1)/2) * spacing)
+			slots = append(slots, formationSlot{center + offset - dir.Mul(float64(row)*spacing), row})
+		}
+	}
+	return slots
+}
+
+// Destinations for each unit (in the same order as us) to form formation at center facing towards facing point.
+// Units with shortest range are placed in the front rows
+func (us Units) FormationPositions(formation Formation, center, facing point.Pointer) point.Points {
+	c := center.Point()
+	dir := (facing.Point() - c).Norm()
+	if dir == 0 {
+		dir = 1
+	}
+	lateral := dir * 1i
+	slots := formationSlots(formation, us.Len(), c, dir, us.formationSpacing())
+
+	units := make(Units, us.Len())
+	copy(units, us)
+	sort.SliceStable(units, func(i, j int) bool {
+		return math.Max(units[i].GroundRange(), units[i].AirRange()) <
+			math.Max(units[j].GroundRange(), units[j].AirRange())
+	})
+	// Project on the lateral axis to keep units from crossing each other paths
+	side := func(p point.Point) float64 {
+		d := p - c
+		return d.X()*lateral.X() + d.Y()*lateral.Y()
+	}
+
+	positions := map[*Unit]point.Point{}
+	for start := 0; start < len(slots); {
+		end := start
+		for end < len(slots) && slots[end].row == slots[start].row {
+			end++
+		}
+		rowSlots := slots[start:end]
+		rowUnits := units[start:end]
+		sort.SliceStable(rowSlots, func(i, j int) bool { return side(rowSlots[i].Point) < side(rowSlots[j].Point) })
+		sort.SliceStable(rowUnits, func(i, j int) bool { return side(rowUnits[i].Point()) < side(rowUnits[j].Point()) })
+		for n, u := range rowUnits {
+			positions[u] = rowSlots[n].Point
+		}
+		start = end
+	}
+
+	ps := make(point.Points, us.Len())
+	for n, u := range us {
+		pos := positions[u]
+		if !u.IsFlying && !B.Grid.IsPathable(pos) {
+			// Narrow places: ramps, chokes
+			if p := B.FindClosestPathable(B.Grid, pos); p != 0 {
+				pos = p.CellCenter()
+			} else {
+				pos = c
+			}
+		}
+		ps[n] = pos
+	}
+	return ps
+}
+
+// Slowest unit speed in the group
+func (us Units) MinSpeed() float64 {
+	speed := math.Inf(1)
+	for _, u := range us {
+		if s := u.Speed(); s > 0 && s < speed {
+			speed = s
+		}
+	}
+	if math.IsInf(speed, 1) {
+		return 0
+	}
+	return speed
+}
+
+// Move group to the target in formation. Should be called every step. Formation advances with speed of the slowest
+// unit and stops to reform if units were spread (ex: after the ramp)
+func (us Units) CommandFormation(formation Formation, target point.Pointer) {
+	if us.Empty() {
+		return
+	}
+	center := us.Center()
+	pos := target.Point()
+	dir := pos - center
+	if dir.Len() < samePoint {
+		us.CommandFormationAt(formation, pos, pos+us.formationFacing())
+		return
+	}
+
+	// Check if formation is still in shape around current center
+	spacing := us.formationSpacing()
+	current := us.FormationPositions(formation, center, pos)
+	spread := 0.0
+	for n, u := range us {
+		spread += u.Dist(current[n])
+	}
+	spread /= float64(us.Len())
+
+	// Ramps are pathable and not buildable. Don't stop there, reform after
+	onRamp := B.Grid.IsPathable(center) && !B.Grid.IsBuildable(center)
+	anchor := center // Reform in place
+	if spread <= spacing*formationSpread || onRamp {
+		// Advance by the slowest unit's step, so faster units will wait on their slots
+		step := us.MinSpeed()*float64(B.FramesPerOrder)/FPS + formationLookahead
+		if center.IsCloserThan(step, pos) {
+			anchor = pos
+		} else {
+			anchor = center.Towards(pos, step)
+		}
+	}
+	us.CommandFormationAt(formation, anchor, pos+dir.Norm())
+}
+
+// Default facing vector if group is already on its target
+func (us Units) formationFacing() point.Point {
+	if B.Locs.EnemyStart != 0 {
+		return (B.Locs.EnemyStart - us.Center()).Norm()
+	}
+	return 1
+}
+
+// Send each unit to its place in the formation at center, facing the point
+func (us Units) CommandFormationAt(formation Formation, center, facing point.Pointer) {
+	for n, pos := range us.FormationPositions(formation, center, facing) {
+		us[n].CommandPos(ability.Move, pos)
+	}
+}
+
+// Move units of the group to the target in formation
+func (gs *Groups) CommandFormation(group GroupID, formation Formation, target point.Pointer) {
+	gs.Get(group).Units.CommandFormation(formation, target)
+}
+
+// Same but for the named group
+func (gs *Groups) CommandFormationN(groupName string, formation Formation, target point.Pointer) {
+	gs.GetN(groupName).Units.CommandFormation(formation, target)
+}
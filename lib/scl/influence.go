@@ -0,0 +1,185 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"math"
+	"sort"
+)
+
+const influenceFalloff = 3  // Influence linearly decreases to zero over this distance outside of the range
+const InfluenceWeight = 0.1 // Default cost multiplier for threat: path through 10 DPS is twice as long
+
+// Float values per map cell
+type InfluenceMap struct {
+	Width, Height int
+	Data          []float64
+}
+
+// Detection ranges for units which DetectRange is unknown (snapshots)
+var DetectorRanges = map[api.UnitTypeID]float64{
+	protoss.Observer: 11, protoss.ObserverSiegeMode: 13.75, protoss.PhotonCannon: 11,
+	terran.MissileTurret: 11, terran.Raven: 11,
+	zerg.Overseer: 11, zerg.OverseerSiegeMode: 13.75, zerg.SporeCrawler: 11,
+}
+
+func NewInfluenceMap(width, height int) *InfluenceMap {
+	return &InfluenceMap{Width: width, Height: height, Data: make([]float64, width*height)}
+}
+
+func (im *InfluenceMap) addr(p point.Point) int {
+	x, y := int(p.X()), int(p.Y())
+	if x < 0 || y < 0 || x >= im.Width || y >= im.Height {
+		return -1
+	}
+	return y*im.Width + x
+}
+
+func (im *InfluenceMap) Clear() {
+	for n := range im.Data {
+		im.Data[n] = 0
+	}
+}
+
+// Value in the cell. Zero outside of the map
+func (im *InfluenceMap) At(ptr point.Pointer) float64 {
+	if addr := im.addr(ptr.Point()); addr != -1 {
+		return im.Data[addr]
+	}
+	return 0
+}
+
+// Add value to all cells within radius of center. Outside of radius value linearly decreases to zero
+func (im *InfluenceMap) Add(ptr point.Pointer, radius, value float64) {
+	center := ptr.Point()
+	r := radius + influenceFalloff
+	for y := math.Floor(center.Y() - r); y <= center.Y()+r; y++ {
+		for x := math.Floor(center.X() - r); x <= center.X()+r; x++ {
+			p := point.Pt(x, y)
+			addr := im.addr(p)
+			if addr == -1 {
+				continue
+			}
+			dist := p.CellCenter().Dist(center)
+			switch {
+			case dist <= radius:
+				im.Data[addr] += value
+			case dist < r:
+				im.Data[addr] += value * (r - dist) / influenceFalloff
+			}
+		}
+	}
+}
+
+// Parameters of points where segment crosses integer lines of one axis
+func crossings(ts []float64, a, b float64) []float64 {
+	lo, hi := math.Min(a, b), math.Max(a, b)
+	for c := math.Floor(lo) + 1; c < hi; c++ {
+		ts = append(ts, (c-a)/(b-a))
+	}
+	return ts
+}
+
+// Call f for each cell crossed by the segment with the length of the segment part inside of the cell
+func cellsAlong(from, to point.Point, f func(p point.Point, length float64)) {
+	dist := from.Dist(to)
+	if dist == 0 {
+		f(from, 0)
+		return
+	}
+	ts := crossings(crossings([]float64{0, 1}, from.X(), to.X()), from.Y(), to.Y())
+	sort.Float64s(ts)
+	for n := 1; n < len(ts); n++ {
+		if ts[n] == ts[n-1] {
+			continue // Segment crosses a grid node
+		}
+		mid := from + (to-from)*point.Pt((ts[n-1]+ts[n])/2, 0)
+		f(mid, dist*(ts[n]-ts[n-1]))
+	}
+}
+
+// Sum of values along the path: each crossed cell adds its value multiplied by the length of the path
+// inside of it. So result is comparable for different paths and doesn't depend on path segmentation
+func (im *InfluenceMap) Along(path point.Points) float64 {
+	sum := 0.0
+	for n := 1; n < path.Len(); n++ {
+		cellsAlong(path[n-1], path[n], func(p point.Point, length float64) {
+			sum += im.At(p) * length
+		})
+	}
+	return sum
+}
+
+// Highest value of cells crossed by the path
+func (im *InfluenceMap) MaxAlong(path point.Points) float64 {
+	max := 0.0
+	for n := 1; n < path.Len(); n++ {
+		cellsAlong(path[n-1], path[n], func(p point.Point, length float64) {
+			max = math.Max(max, im.At(p))
+		})
+	}
+	return max
+}
+
+// Cost function for path search: threat is converted into additional distance
+func (im *InfluenceMap) Cost(weight float64) func(p point.Point) float64 {
+	return func(p point.Point) float64 {
+		return 1 + weight*im.At(p)
+	}
+}
+
+func detectRange(u *Unit) float64 {
+	if u.DetectRange > 0 {
+		return float64(u.DetectRange)
+	}
+	return DetectorRanges[u.UnitType]
+}
+
+// Recalculate all influence layers. Called each step
+func (b *Bot) UpdateInfluence() {
+	inf := &b.Influence
+	if inf.GroundThreat == nil {
+		size := b.Info.StartRaw.MapSize
+		w, h := int(size.X), int(size.Y)
+		inf.GroundThreat = NewInfluenceMap(w, h)
+		inf.AirThreat = NewInfluenceMap(w, h)
+		inf.Detection = NewInfluenceMap(w, h)
+		inf.Support = NewInfluenceMap(w, h)
+	}
+	inf.GroundThreat.Clear()
+	inf.AirThreat.Clear()
+	inf.Detection.Clear()
+	inf.Support.Clear()
+
+	for _, u := range b.Enemies.AllReady {
+		// Ranges are increased by unit radius and an average target radius
+		if dps := u.GroundDPS(); dps > 0 {
+			inf.GroundThreat.Add(u, u.GroundRange()+float64(u.Radius)+0.5, dps)
+		}
+		if dps := u.AirDPS(); dps > 0 {
+			inf.AirThreat.Add(u, u.AirRange()+float64(u.Radius)+0.5, dps)
+		}
+		if r := detectRange(u); r > 0 {
+			inf.Detection.Add(u, r, 1)
+		}
+	}
+	for _, u := range b.Units.MyAll {
+		if !u.IsReady() {
+			continue
+		}
+		if dps := math.Max(u.GroundDPS(), u.AirDPS()); dps > 0 {
+			inf.Support.Add(u, math.Max(u.GroundRange(), u.AirRange())+float64(u.Radius)+0.5, dps)
+		}
+	}
+}
+
+// Threat layer for the unit
+func (u *Unit) ThreatMap() *InfluenceMap {
+	if u.IsFlying {
+		return B.Influence.AirThreat
+	}
+	return B.Influence.GroundThreat
+}
@@ -0,0 +1,125 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestInfluenceMap_Add(t *testing.T) {
+	im := NewInfluenceMap(32, 32)
+	center := point.Pt(10.5, 10.5)
+	im.Add(center, 2, 6)
+	for _, c := range []struct {
+		p        point.Point
+		expected float64
+	}{
+		{point.Pt(10, 10), 6},
+		{point.Pt(12, 10), 6}, // On the range edge
+		{point.Pt(10, 8), 6},  // On the range edge
+		{point.Pt(12, 12), 6 * (5 - 2*math.Sqrt2) / influenceFalloff},
+		{point.Pt(13.9, 10), 4}, // Cell (13, 10): 1 of 3 cells of falloff
+		{point.Pt(14, 10), 2},
+		{point.Pt(15, 10), 0}, // End of falloff
+		{point.Pt(10, 16), 0},
+	} {
+		if v := im.At(c.p); math.Abs(v-c.expected) > 1e-9 {
+			t.Errorf("value at %v: %v, expected %v", c.p, v, c.expected)
+		}
+	}
+
+	im.Add(center, 2, 6)
+	if v := im.At(point.Pt(11, 11)); v != 12 {
+		t.Errorf("values are not summed: %v", v)
+	}
+	im.Clear()
+	if v := im.At(center); v != 0 {
+		t.Errorf("value after clear: %v", v)
+	}
+}
+
+func TestInfluenceMap_AddBorder(t *testing.T) {
+	im := NewInfluenceMap(16, 8)
+	im.Add(point.Pt(0.5, 7.5), 1, 3)  // Top left corner
+	im.Add(point.Pt(17, -2), 4, 3)    // Outside of the map, but falloff reaches it
+	im.Add(point.Pt(-20, -20), 10, 3) // Too far
+	if v := im.At(point.Pt(0, 7)); v != 3 {
+		t.Errorf("value in the corner: %v", v)
+	}
+	// (15, 0) is 2.92 from (17, -2): in range of 4
+	if v := im.At(point.Pt(15, 0)); v != 3 {
+		t.Errorf("value near the outer center: %v", v)
+	}
+	if v := im.At(point.Pt(8, 4)); v != 0 {
+		t.Errorf("value in the middle: %v", v)
+	}
+	for _, p := range []point.Point{point.Pt(-1, 7), point.Pt(0, 8), point.Pt(16, 0), point.Pt(15, -1)} {
+		if v := im.At(p); v != 0 {
+			t.Errorf("value outside of the map at %v: %v", p, v)
+		}
+	}
+}
+
+func TestInfluenceMap_Along(t *testing.T) {
+	im := NewInfluenceMap(16, 16)
+	for y := 0; y < 16; y++ {
+		for x := 8; x < 16; x++ {
+			im.Data[y*im.Width+x] = 2 // Right half of the map
+		}
+	}
+	im.Data[3*im.Width+9] = 5
+
+	path := point.Points{point.Pt(2.5, 3.5), point.Pt(12.5, 3.5)}
+	// 4.5 cells of the path are on the right half, one of them is the peak
+	if v := im.Along(path); math.Abs(v-(3.5*2+5)) > 1e-9 {
+		t.Errorf("along: %v", v)
+	}
+	if v := im.MaxAlong(path); v != 5 {
+		t.Errorf("max along: %v", v)
+	}
+	// Diagonal which ends at the corner of the peak cell
+	if v := im.MaxAlong(point.Points{point.Pt(6, 0), point.Pt(9, 3)}); v != 2 {
+		t.Errorf("max along diagonal: %v", v)
+	}
+	if v := im.Along(point.Points{point.Pt(1, 1), point.Pt(1, 1)}); v != 0 {
+		t.Errorf("along of zero length: %v", v)
+	}
+
+	// Split points of the path don't change the result
+	rnd := rand.New(rand.NewSource(1))
+	for n := range im.Data {
+		im.Data[n] = rnd.Float64()
+	}
+	for k := 0; k < 100; k++ {
+		from := point.Pt(rnd.Float64()*16, rnd.Float64()*16)
+		to := point.Pt(rnd.Float64()*16, rnd.Float64()*16)
+		whole := point.Points{from, to}
+		split := point.Points{from}
+		ts := []float64{rnd.Float64(), rnd.Float64(), rnd.Float64()}
+		for _, t := range []float64{math.Min(ts[0], ts[1]), math.Max(ts[0], ts[1]), 1} {
+			split.Add(from + (to-from)*point.Pt(t, 0))
+		}
+		if a, b := im.Along(whole), im.Along(split); math.Abs(a-b) > 1e-9 {
+			t.Fatalf("%v: %v, split %v: %v", whole, a, split, b)
+		}
+		if a, b := im.MaxAlong(whole), im.MaxAlong(split); a != b {
+			t.Fatalf("%v: max %v, split %v: max %v", whole, a, split, b)
+		}
+	}
+}
+
+func TestInfluenceMap_Cost(t *testing.T) {
+	im := NewInfluenceMap(8, 8)
+	im.Data[2*im.Width+3] = 10
+	cost := im.Cost(InfluenceWeight)
+	if c := cost(point.Pt(3, 2)); c != 2 {
+		t.Errorf("cost in 10 DPS: %v", c)
+	}
+	if c := cost(point.Pt(4, 2)); c != 1 {
+		t.Errorf("cost without threat: %v", c)
+	}
+	if c := cost(point.Pt(-1, 2)); c != 1 {
+		t.Errorf("cost outside of the map: %v", c)
+	}
+}
@@ -0,0 +1,124 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"math"
+)
+
+const kiteGap = 1      // Distance that unit tries to keep from enemy range border
+const kiteDistance = 4 // How far unit retreats in one step
+
+// Enemies that can shoot the unit
+func (u *Unit) Threats(enemies Units) Units {
+	return enemies.Filter(func(unit *Unit) bool {
+		return unit.WeaponAgainst(u) != nil
+	})
+}
+
+// Is there any point in kiting the enemy: unit should outrange it or be faster
+func (u *Unit) CanKite(enemy *Unit) bool {
+	if u.WeaponAgainst(enemy) == nil {
+		return true // We can't shoot it anyway, but we can run
+	}
+	rangeAdvantage := enemy.RangeDelta(u, 0) - u.RangeDelta(enemy, 0) // Our range minus their range
+	return rangeAdvantage > 0 || u.Speed() > enemy.Speed()
+}
+
+// Point where unit should retreat from threats. For ground units path goes through safe grid
+func (u *Unit) KitePos(threats Units) point.Point {
+	away := u.Towards(threats.Center(), -kiteDistance)
+	if u.IsFlying {
+		pos, _ := u.AirEvade(threats, kiteGap, away)
+		return pos
+	}
+	navGrid, waymap := u.GetWayMap(true)
+	if !navGrid.IsPathable(away) {
+		if pos := B.FindClosestPathable(navGrid, away); pos != 0 {
+			away = pos
+		} else {
+			pos, _ = u.GroundEvade(threats, kiteGap, away)
+			return pos
+		}
+	}
+	path, _ := NavPath(navGrid, waymap, u, away)
+	if pos := path.FirstFurtherThan(2, u); pos != 0 {
+		return pos
+	}
+	if path.Len() > 1 {
+		return path[path.Len()-1]
+	}
+	// No path by safe grid, evade as we can
+	pos, _ := u.GroundEvade(threats, kiteGap, away)
+	return pos
+}
+
+// Stutter-step vs enemies: shoot when weapon is ready, retreat while it is on cooldown.
+// Returns false if there is nothing to kite and unit is free for other commands
+func (u *Unit) Kite(targets Units) bool {
+	if B.U.UnitsOrders[u.Tag].Loop+B.FramesPerOrder > B.Loop {
+		return true // Not more than FramesPerOrder
+	}
+	if !u.IsCoolToMove() {
+		return true // Attack animation is not finished yet
+	}
+	if u.EvadeEffects() {
+		return true
+	}
+
+	threats := u.Threats(targets.Filter(Ready))
+	closest := threats.Min(func(unit *Unit) float64 {
+		return unit.RangeDelta(u, 0)
+	})
+	danger := closest != nil && closest.RangeDelta(u, kiteGap) <= 0 && u.CanKite(closest)
+
+	if u.IsCoolToAttack() {
+		if u.IsAlreadyAttackingTargetInRange() || DefaultAttackFunc(u, 0, targets) {
+			return true
+		}
+		if danger {
+			// Weapon is ready, but nothing to shoot and enemy is close. Someone outranges us
+			u.CommandPos(ability.Move, u.KitePos(threats))
+			return true
+		}
+		target := targets.Filter(Visible).ClosestTo(u)
+		if target == nil {
+			return false
+		}
+		DefaultMoveFunc(u, target)
+		return true
+	}
+
+	// Weapon is on cooldown. Time to walk
+	if danger {
+		u.CommandPos(ability.Move, u.KitePos(threats))
+		return true
+	}
+	target := targets.Filter(Visible).Min(func(unit *Unit) float64 {
+		return u.RangeDelta(unit, 0)
+	})
+	if target == nil {
+		return false
+	}
+	// Distance unit can pass until the weapon is ready
+	walk := float64(u.WeaponCooldown) / FPS * u.Speed()
+	if delta := u.RangeDelta(target, 0); delta > 0 && delta < walk {
+		// Step forward to shoot as soon as weapon is ready
+		pos := u.Towards(target, math.Min(delta+0.5, kiteDistance))
+		// Copy of unit on the new position
+		cu := *u
+		cu.Pos = pos.To3D()
+		if closest != nil && u.CanKite(closest) && closest.InRange(&cu, kiteGap) {
+			return true // Stepping forward puts us in danger
+		}
+		u.CommandPos(ability.Move, pos)
+	}
+	return true
+}
+
+// Kite for each unit. Units that have nothing to kite receive no orders
+func (us Units) Kite(targets Units) {
+	for _, u := range us {
+		u.Kite(targets)
+	}
+}
@@ -0,0 +1,198 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"sort"
+)
+
+type LayoutUse int
+
+const (
+	UseTownhall LayoutUse = iota + 1
+	UseResources
+	UsePath
+	UseBuilding
+)
+
+const layoutRampRadius = 4 // Keep area on top of the ramp clear
+const PylonPowerRadius = 6.5
+
+type Layout struct {
+	Reserved   map[point.Point]LayoutUse
+	Buildings  map[point.Point]BuildingSize // Planned positions
+	candidates map[BuildingSize]point.Points
+	pylons     point.Points // Planned pylons
+}
+
+// Buildings that are not 3x3
+var BuildingSizes = map[api.UnitTypeID]BuildingSize{
+	terran.SupplyDepot: S2x2, terran.MissileTurret: S2x2, terran.SensorTower: S2x2,
+	protoss.Pylon: S2x2, protoss.DarkShrine: S2x2, protoss.ShieldBattery: S2x2, protoss.PhotonCannon: S2x2,
+	zerg.SpineCrawler: S2x2, zerg.SporeCrawler: S2x2,
+	// Production with room for add-on
+	terran.Barracks: S5x3, terran.Factory: S5x3, terran.Starport: S5x3,
+	terran.CommandCenter: S5x5, protoss.Nexus: S5x5, zerg.Hatchery: S5x5,
+}
+
+func LayoutSize(uType api.UnitTypeID) BuildingSize {
+	if size, ok := BuildingSizes[uType]; ok {
+		return size
+	}
+	return S3x3
+}
+
+// Cells around the building that should stay pathable. Position is the center of the building
+func (b *Bot) BuildingPathableCells(pos point.Point, size BuildingSize, cells PathableCells) point.Points {
+	if size == S2x2 {
+		return b.GetPathablePoints(pos-1-1i, size, cells)
+	}
+	return b.GetPathablePoints(pos, size, cells)
+}
+
+// Create layout for the main base. Mineral lines, paths to the natural and the ramp are reserved
+func (b *Bot) NewLayout() *Layout {
+	l := &Layout{
+		Reserved:   map[point.Point]LayoutUse{},
+		Buildings:  map[point.Point]BuildingSize{},
+		candidates: map[BuildingSize]point.Points{},
+	}
+	for _, base := range append(point.Points{b.Locs.MyStart}, b.Locs.MyExps...) {
+		l.reserveBase(base)
+	}
+	// Paths between start location and first expansions
+	prev := b.Locs.MyStart
+	for n := 0; n < MinInt(2, b.Locs.MyExps.Len()); n++ {
+		path, _ := b.Path(prev, b.Locs.MyExps[n])
+		for _, p := range path {
+			l.reserve(append(p.Neighbours8(1), p), UsePath)
+		}
+		prev = b.Locs.MyExps[n]
+	}
+	if top := b.Ramps.My.Top; top != 0 {
+		var ps point.Points
+		for y := top.Y() - layoutRampRadius; y <= top.Y()+layoutRampRadius; y++ {
+			for x := top.X() - layoutRampRadius; x <= top.X()+layoutRampRadius; x++ {
+				if p := point.Pt(x, y); p.IsCloserThan(layoutRampRadius, top) {
+					ps.Add(p)
+				}
+			}
+		}
+		l.reserve(ps, UsePath)
+	}
+	l.AddBase(b.Locs.MyStart)
+	return l
+}
+
+func (l *Layout) reserve(ps point.Points, use LayoutUse) {
+	for _, p := range ps {
+		if l.Reserved[p] < use {
+			l.Reserved[p] = use
+		}
+	}
+}
+
+// Townhall place and cells between it and resources
+func (l *Layout) reserveBase(base point.Point) {
+	l.reserve(B.BuildingPathableCells(base, S5x5, One), UseTownhall)
+	resources := append(B.Units.Minerals.All(), B.Units.Geysers.All()...).CloserThan(ResourceSpreadDistance, base)
+	for _, r := range resources {
+		dist := base.Dist(r)
+		for d := 0.0; d <= dist; d += 0.5 {
+			p := base.Towards(r, d).Floor()
+			l.reserve(append(p.Neighbours8(1), p), UseResources)
+		}
+	}
+}
+
+// Allow buildings in the buildable area around the base. Positions are handed out starting from the closest
+// to the base, so the order is always the same for the same map
+func (l *Layout) AddBase(base point.Point) {
+	// Townhall footprint is already unbuildable, so cluster is collected from cells around it
+	var cluster point.Points
+	visited := map[point.Point]bool{}
+	for _, p := range B.GetBuildingPoints(base, S5x5) {
+		visited[p] = true
+	}
+	for _, p := range B.BuildingPathableCells(base, S5x5, One) {
+		B.FindBaseCluster(p, &cluster, visited)
+	}
+	sort.SliceStable(cluster, func(i, j int) bool {
+		di, dj := cluster[i].Dist2(base), cluster[j].Dist2(base)
+		if di != dj {
+			return di < dj
+		}
+		if cluster[i].Y() != cluster[j].Y() {
+			return cluster[i].Y() < cluster[j].Y()
+		}
+		return cluster[i].X() < cluster[j].X()
+	})
+	for _, size := range []BuildingSize{S2x2, S3x3, S5x3} {
+		for _, p := range cluster {
+			// p is the lower left cell of 2x2 building or the center cell of others
+			l.candidates[size] = append(l.candidates[size], buildingCenter(p, size))
+		}
+	}
+}
+
+func (l *Layout) isPosOk(pos point.Point, size BuildingSize) bool {
+	for _, p := range B.BuildingCells(pos, size) {
+		if l.Reserved[p] != 0 || !B.Grid.IsBuildable(p) {
+			return false
+		}
+	}
+	if size == S2x2 {
+		return true // Small buildings could be packed, paths are kept by reserved cells
+	}
+	// Units should be able to walk around production
+	for _, p := range B.BuildingPathableCells(pos, size, One) {
+		if l.Reserved[p] == UseBuilding || !B.Grid.IsPathable(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Layout) isPowered(pos point.Point) bool {
+	for _, pylon := range B.Units.My[protoss.Pylon].Filter(Ready) {
+		if pylon.IsCloserThan(PylonPowerRadius, pos) {
+			return true
+		}
+	}
+	return l.pylons.CloserThan(PylonPowerRadius, pos).Exists()
+}
+
+// Next position for the building of that type. Returns 0 if there is no room (or no power for protoss buildings).
+// Townhalls are not planned here, use expansions locations for them
+func (l *Layout) Next(uType api.UnitTypeID) point.Point {
+	size := LayoutSize(uType)
+	needsPower := B.U.Types[uType].Race == api.Race_Protoss && uType != protoss.Pylon && uType != protoss.Nexus
+	for _, pos := range l.candidates[size] {
+		if !l.isPosOk(pos, size) || (needsPower && !l.isPowered(pos)) {
+			continue
+		}
+		l.reserve(B.BuildingCells(pos, size), UseBuilding)
+		l.Buildings[pos] = size
+		if uType == protoss.Pylon {
+			l.pylons.Add(pos)
+		}
+		return pos
+	}
+	return 0
+}
+
+// Free reserved position if building was not placed
+func (l *Layout) Release(pos point.Point) {
+	size, ok := l.Buildings[pos]
+	if !ok {
+		return
+	}
+	for _, p := range B.BuildingCells(pos, size) {
+		delete(l.Reserved, p)
+	}
+	delete(l.Buildings, pos)
+	l.pylons.Remove(pos)
+}
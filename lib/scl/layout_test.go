@@ -0,0 +1,44 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"testing"
+)
+
+// Bot with a flat buildable main base in the middle of the map
+func testLayoutBot() *Bot {
+	b := testNavBot()
+	start := point.Pt(100.5, 100.5)
+	for y := 80.0; y < 120; y++ {
+		for x := 80.0; x < 120; x++ {
+			b.Grid.SetPathable(point.Pt(x, y), true)
+			b.Grid.SetBuildable(point.Pt(x, y), true)
+		}
+	}
+	b.Locs.MyStart = start
+	for _, p := range b.GetBuildingPoints(start, S5x5) {
+		b.Grid.SetBuildable(p, false) // Townhall, like ParseUnits does
+	}
+	b.U.Types = make([]*api.UnitTypeData, terran.Barracks+1)
+	b.U.Types[terran.SupplyDepot] = &api.UnitTypeData{Race: api.Race_Terran}
+	b.U.Types[terran.Barracks] = &api.UnitTypeData{Race: api.Race_Terran}
+	return b
+}
+
+func TestLayout_Next(t *testing.T) {
+	b := testLayoutBot()
+	l := b.NewLayout()
+	for _, uType := range []api.UnitTypeID{terran.SupplyDepot, terran.Barracks, terran.Barracks} {
+		pos := l.Next(uType)
+		if pos == 0 {
+			t.Fatalf("no position for %v", uType)
+		}
+		for _, p := range b.BuildingCells(pos, LayoutSize(uType)) {
+			if !b.Grid.IsBuildable(p) {
+				t.Fatalf("%v at %v covers unbuildable cell %v", uType, pos, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"github.com/aiseeq/s2l/protocol/enums/buff"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"github.com/aiseeq/s2l/protocol/enums/terran"
+	"github.com/aiseeq/s2l/protocol/enums/zerg"
+	"sort"
+)
+
+const muleEnergy = 50
+const injectEnergy = 25
+const chronoEnergy = 50
+const PrismPowerRadius = 3.75
+const warpInSpacing = 1.5 // Min distance between warped units
+
+// Macro managers are opt-in: create the ones you need and call Step() every frame after units are parsed
+
+type MuleManager struct {
+	Reserve float64 // Energy that should be left on orbitals (ex: for scans)
+}
+
+type InjectManager struct {
+	Queens  Tags                        // Queens that inject. Other queens are not touched
+	Hatches map[api.UnitTag]api.UnitTag // Queen -> assigned hatchery
+}
+
+type ChronoManager struct {
+	Priority []api.UnitTypeID // Structures that could be boosted from higher to lower priority
+}
+
+type WarpManager struct {
+	Morph bool // Morph gateways into warpgates when research is done
+}
+
+var WarpAbilities = map[api.UnitTypeID]api.AbilityID{
+	protoss.Zealot:      ability.TrainWarp_Zealot,
+	protoss.Stalker:     ability.TrainWarp_Stalker,
+	protoss.Sentry:      ability.TrainWarp_Sentry,
+	protoss.Adept:       ability.TrainWarp_Adept,
+	protoss.HighTemplar: ability.TrainWarp_HighTemplar,
+	protoss.DarkTemplar: ability.TrainWarp_DarkTemplar,
+}
+
+func NewMuleManager() *MuleManager {
+	return &MuleManager{}
+}
+
+// Mineral field near our ready townhalls with the most minerals left
+func (mm *MuleManager) Target() *Unit {
+	var best *Unit
+	ccs := B.Units.My.OfType(B.U.UnitAliases.For(terran.CommandCenter)...).Filter(Ready)
+	for _, cc := range ccs {
+		for _, mf := range B.Units.Minerals.All().CloserThan(ResourceSpreadDistance, cc) {
+			if best == nil || mf.MineralContents > best.MineralContents {
+				best = mf
+			}
+		}
+	}
+	return best
+}
+
+func (mm *MuleManager) Step() {
+	var target *Unit
+	for _, oc := range B.Units.My[terran.OrbitalCommand] {
+		if float64(oc.Energy) < muleEnergy+mm.Reserve || !oc.HasAbility(ability.Effect_CalldownMULE) {
+			continue
+		}
+		if target == nil {
+			if target = mm.Target(); target == nil {
+				return
+			}
+		}
+		oc.CommandTag(ability.Effect_CalldownMULE, target.Tag)
+	}
+}
+
+func NewInjectManager() *InjectManager {
+	return &InjectManager{Hatches: map[api.UnitTag]api.UnitTag{}}
+}
+
+// Each hatchery gets one queen. Free queens are assigned to the closest hatcheries without queens
+func (im *InjectManager) assign(hatches Units) {
+	queens := B.Units.My[zerg.Queen].ByTags(im.Queens)
+	taken := map[api.UnitTag]bool{}
+	for queen, hatch := range im.Hatches {
+		if queens.ByTag(queen) == nil || hatches.ByTag(hatch) == nil {
+			delete(im.Hatches, queen)
+			continue
+		}
+		taken[hatch] = true
+	}
+	for _, queen := range queens {
+		if _, ok := im.Hatches[queen.Tag]; ok {
+			continue
+		}
+		var best *Unit
+		for _, hatch := range hatches {
+			if !taken[hatch.Tag] && (best == nil || queen.Dist2(hatch) < queen.Dist2(best)) {
+				best = hatch
+			}
+		}
+		if best == nil {
+			return // Queen stays free until new hatchery appears
+		}
+		im.Hatches[queen.Tag] = best.Tag
+		taken[best.Tag] = true
+	}
+}
+
+// Queen was already ordered to inject (it might have other orders queued before)
+func isInjecting(queen *Unit) bool {
+	for _, order := range queen.Orders {
+		if order.AbilityId == ability.Effect_InjectLarva {
+			return true
+		}
+	}
+	return false
+}
+
+func canInject(queen, hatch *Unit) bool {
+	return float64(queen.Energy) >= injectEnergy && !isInjecting(queen) && queen.HasAbility(ability.Effect_InjectLarva) &&
+		!hatch.HasBuff(buff.QueenSpawnLarvaTimer)
+}
+
+// Queens inject their hatcheries. Spare queens help hatcheries with the least larva which queens have no energy
+func (im *InjectManager) Step() {
+	hatches := B.Units.My.OfType(zerg.Hatchery, zerg.Lair, zerg.Hive).Filter(Ready)
+	im.assign(hatches)
+	injected := map[api.UnitTag]bool{}
+	for _, queen := range B.Units.My[zerg.Queen] {
+		if queen.TargetAbility() == ability.Effect_InjectLarva {
+			injected[queen.TargetTag()] = true
+		}
+	}
+	for queen, hatchTag := range im.Hatches {
+		q := B.Units.ByTag[queen]
+		hatch := hatches.ByTag(hatchTag)
+		if q == nil || hatch == nil || injected[hatch.Tag] || !canInject(q, hatch) {
+			continue
+		}
+		q.CommandTag(ability.Effect_InjectLarva, hatch.Tag)
+		injected[hatch.Tag] = true
+	}
+
+	larva := B.Units.My[zerg.Larva]
+	hatches.OrderBy(func(u *Unit) float64 { return float64(larva.CloserThan(ResourceSpreadDistance, u).Len()) }, false)
+	for _, q := range B.Units.My[zerg.Queen].ByTags(im.Queens) {
+		if _, ok := im.Hatches[q.Tag]; ok {
+			continue
+		}
+		for _, hatch := range hatches {
+			if !injected[hatch.Tag] && canInject(q, hatch) {
+				q.CommandTag(ability.Effect_InjectLarva, hatch.Tag)
+				injected[hatch.Tag] = true
+				break
+			}
+		}
+	}
+}
+
+func NewChronoManager(priority ...api.UnitTypeID) *ChronoManager {
+	if len(priority) == 0 {
+		priority = []api.UnitTypeID{protoss.Forge, protoss.TwilightCouncil, protoss.CyberneticsCore,
+			protoss.RoboticsFacility, protoss.Stargate, protoss.Gateway, protoss.Nexus}
+	}
+	return &ChronoManager{Priority: priority}
+}
+
+// Busy structures that are not boosted yet ordered by priority and then by the length of the queue
+func (cm *ChronoManager) Targets() Units {
+	rank := map[api.UnitTypeID]int{}
+	for n, uType := range cm.Priority {
+		rank[uType] = n
+	}
+	var targets Units
+	for _, u := range B.Units.My.OfType(cm.Priority...) {
+		if u.IsReady() && len(u.Orders) > 0 && !u.HasBuff(buff.ChronoBoostEnergyCost) {
+			targets.Add(u)
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		ri, rj := rank[targets[i].UnitType], rank[targets[j].UnitType]
+		if ri != rj {
+			return ri < rj
+		}
+		return len(targets[i].Orders) > len(targets[j].Orders)
+	})
+	return targets
+}
+
+func (cm *ChronoManager) Step() {
+	targets := cm.Targets()
+	nexuses := B.Units.My[protoss.Nexus].Filter(Ready)
+	// Don't boost structures that other nexuses are going to boost
+	for _, nexus := range nexuses {
+		if nexus.TargetAbility() == ability.Effect_ChronoBoostEnergyCost {
+			targets.RemoveTag(nexus.TargetTag())
+		}
+	}
+	for _, nexus := range nexuses {
+		if targets.Empty() {
+			return
+		}
+		if float64(nexus.Energy) < chronoEnergy || !nexus.HasAbility(ability.Effect_ChronoBoostEnergyCost) {
+			continue
+		}
+		target := targets[0]
+		nexus.CommandTag(ability.Effect_ChronoBoostEnergyCost, target.Tag)
+		targets.Remove(target)
+	}
+}
+
+func NewWarpManager() *WarpManager {
+	return &WarpManager{Morph: true}
+}
+
+// Research warpgate and morph gateways
+func (wm *WarpManager) Step() {
+	if !B.Upgrades[ability.Research_WarpGate] {
+		if B.Orders[ability.Research_WarpGate] > 0 || !B.CanBuy(ability.Research_WarpGate) {
+			return
+		}
+		if cc := B.Units.My[protoss.CyberneticsCore].First(Ready, Idle); cc != nil {
+			cc.Command(ability.Research_WarpGate)
+			B.DeductResources(ability.Research_WarpGate)
+		}
+		return
+	}
+	if !wm.Morph {
+		return
+	}
+	for _, gate := range B.Units.My[protoss.Gateway].Filter(Ready, Idle) {
+		if gate.HasAbility(ability.Morph_WarpGate) {
+			gate.Command(ability.Morph_WarpGate)
+		}
+	}
+}
+
+// Free pathable points in power fields of pylons and warp prisms ordered by distance to the target
+func (wm *WarpManager) Positions(target point.Point) point.Points {
+	type source struct {
+		pos point.Point
+		r   float64
+	}
+	var sources []source
+	for _, pylon := range B.Units.My[protoss.Pylon].Filter(Ready) {
+		sources = append(sources, source{pylon.Point(), PylonPowerRadius})
+	}
+	for _, prism := range B.Units.My[protoss.WarpPrismPhasing] {
+		sources = append(sources, source{prism.Point(), PrismPowerRadius})
+	}
+	seen := map[point.Point]bool{}
+	var ps point.Points
+	for _, s := range sources {
+		c := s.pos.Floor()
+		for y := c.Y() - s.r; y <= c.Y()+s.r; y++ {
+			for x := c.X() - s.r; x <= c.X()+s.r; x++ {
+				p := point.Pt(x, y).CellCenter()
+				if seen[p] || !p.IsCloserThan(s.r, s.pos) || !B.Grid.IsPathable(p) {
+					continue
+				}
+				seen[p] = true
+				if B.Units.MyAll.Filter(Ground).CloserThan(warpInSpacing, p).Exists() ||
+					B.Enemies.All.Filter(Ground).CloserThan(warpInSpacing, p).Exists() {
+					continue
+				}
+				ps.Add(p)
+			}
+		}
+	}
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].Dist2(target) < ps[j].Dist2(target) })
+	return ps
+}
+
+// Warp units of given type as close to the target as possible using ready warpgates. Returns number of
+// ordered units
+func (wm *WarpManager) WarpIn(uType api.UnitTypeID, count int, target point.Point) int {
+	aid, ok := WarpAbilities[uType]
+	if !ok {
+		return 0
+	}
+	train := B.U.UnitAbility[uType]
+	gates := B.Units.My[protoss.WarpGate].Filter(Ready)
+	var ps, used point.Points
+	warped := 0
+	for _, gate := range gates {
+		if warped >= count || !B.CanBuy(train) {
+			break
+		}
+		if !gate.HasAbility(aid) {
+			continue // Cooldown
+		}
+		if ps == nil {
+			if ps = wm.Positions(target); ps.Empty() {
+				break
+			}
+		}
+		var pos point.Point
+		for _, p := range ps {
+			if !used.CloserThan(warpInSpacing, p).Exists() {
+				pos = p
+				break
+			}
+		}
+		if pos == 0 {
+			break
+		}
+		used.Add(pos)
+		gate.CommandPos(aid, pos)
+		B.DeductResources(train)
+		warped++
+	}
+	return warped
+}
@@ -53,6 +53,7 @@ const (
 	Zero PathableCells = iota
 	One
 	Two
+	Three
 )
 
 var DestructibleSize = map[api.UnitTypeID]BuildingSize{
@@ -235,6 +236,79 @@ func (b *Bot) GetBuildingPoints(ptr point.Pointer, size BuildingSize) point.Poin
 	return nil
 }
 
+// Cells occupied by mineral field or destructible. Second value is true if they are not pathable
+func (b *Bot) NeutralFootprint(u *Unit) (point.Points, bool) {
+	var size BuildingSize = 0
+	var unpathable bool
+	pos := u.Point()
+	if u.IsMineral() {
+		size = S2x1
+		pos -= 1
+		unpathable = true
+	} else {
+		size = DestructibleSize[u.UnitType]
+		switch size {
+		case UnbuildableRocks:
+			pos -= 1 + 1i
+		case BreakableRocks2x2:
+			pos -= 1 + 1i
+			unpathable = true
+		case BreakableRocks4x4:
+			unpathable = true
+		case BreakableRocks4x2:
+			unpathable = true
+		case BreakableRocks2x4:
+			unpathable = true
+		case BreakableRocks6x2:
+			unpathable = true
+		case BreakableRocks2x6:
+			unpathable = true
+		case BreakableRocks6x6:
+			unpathable = true
+		case BreakableRocksDiagBLUR:
+			unpathable = true
+		case BreakableRocksDiagULBR:
+			unpathable = true
+		case BreakableHorizontalHuge:
+			unpathable = true
+		case BreakableVerticalHuge:
+			unpathable = true
+		default:
+			// log.Info(u.UnitType)
+		}
+	}
+	if size == 0 {
+		return nil, false
+	}
+	return b.GetBuildingPoints(pos, size), unpathable
+}
+
+// Building footprint grown by the cells count in every direction. Corner cells further than cells+1 from
+// the footprint by manhattan distance are removed, because units pass around corners diagonally
+func (b *Bot) grownBuildingPoints(pos point.Point, size BuildingSize, cells PathableCells) point.Points {
+	building := b.GetBuildingPoints(pos, size)
+	min, max := building[0], building[0]
+	for _, p := range building {
+		min = point.Pt(math.Min(min.X(), p.X()), math.Min(min.Y(), p.Y()))
+		max = point.Pt(math.Max(max.X(), p.X()), math.Max(max.Y(), p.Y()))
+	}
+	c := float64(cells)
+	ps := point.Points{}
+	for y := min.Y() - c; y <= max.Y()+c; y++ {
+		for x := min.X() - c; x <= max.X()+c; x++ {
+			dx := math.Max(0, math.Max(min.X()-x, x-max.X()))
+			dy := math.Max(0, math.Max(min.Y()-y, y-max.Y()))
+			if dx > 0 && dy > 0 && dx+dy > c+1 {
+				continue
+			}
+			ps.Add(point.Pt(x, y))
+		}
+	}
+	return ps
+}
+
+// Cells that should be pathable so units could walk around the building: footprint with the border of cells
+// width. Corners of wide borders are cut. Returns nil for sizes that are not buildings
 func (b *Bot) GetPathablePoints(ptr point.Pointer, size BuildingSize, cells PathableCells) point.Points {
 	if cells == Zero {
 		return b.GetBuildingPoints(ptr, size)
@@ -260,8 +334,11 @@ func (b *Bot) GetPathablePoints(ptr point.Pointer, size BuildingSize, cells Path
 			ps = append(b.GetPathablePoints(pos, S3x3, One), b.GetPathablePoints(pos+2-1i, S2x2, One)...)
 		case S5x5:
 			ps = b.GetPathablePoints(pos, S3x3, Two)
+		case S2x1:
+			ps = b.grownBuildingPoints(pos, size, cells)
 		default:
-			log.Fatalf("Building size %v is not implemented for cells count %v", size, cells)
+			log.Errorf("Building size %v is not implemented for cells count %v", size, cells)
+			return nil
 		}
 		return ps
 	}
@@ -288,15 +365,44 @@ func (b *Bot) GetPathablePoints(ptr point.Pointer, size BuildingSize, cells Path
 		case S5x3:
 			// todo: optimize - remove intersection
 			ps = append(b.GetPathablePoints(pos, S3x3, Two), b.GetPathablePoints(pos+2-1i, S2x2, Two)...)
+		case S2x1, S5x5:
+			ps = b.grownBuildingPoints(pos, size, cells)
+		default:
+			log.Errorf("Building size %v is not implemented for cells count %v", size, cells)
+			return nil
+		}
+		return ps
+	}
+	if cells == Three {
+		switch size {
+		case S2x1, S2x2, S3x3, S5x5:
+			ps = b.grownBuildingPoints(pos, size, cells)
+		case S5x3:
+			ps = append(b.GetPathablePoints(pos, S3x3, Three), b.GetPathablePoints(pos+2-1i, S2x2, Three)...)
 		default:
-			log.Fatalf("Building size %v is not implemented for cells count %v", size, cells)
+			log.Errorf("Building size %v is not implemented for cells count %v", size, cells)
+			return nil
 		}
 		return ps
 	}
-	log.Fatalf("Cells count %v is not implemented", cells)
+	log.Errorf("Cells count %v is not implemented", cells)
 	return nil
 }
 
+// How many free cells are needed between buildings so unit with the radius could pass
+func CellsForRadius(radius float64) PathableCells {
+	cells := PathableCells(math.Ceil(radius * 2))
+	if cells > Three {
+		return Three
+	}
+	return cells
+}
+
+// Building position leaves enough free cells around for units with the radius
+func (b *Bot) IsPosOkForRadius(ptr point.Pointer, size BuildingSize, radius float64, flags ...CheckMap) bool {
+	return b.IsPosOk(ptr, size, CellsForRadius(radius), flags...)
+}
+
 func (b *Bot) IsPosOk(ptr point.Pointer, size BuildingSize, cells PathableCells, flags ...CheckMap) bool {
 	ps := b.GetBuildingPoints(ptr, size)
 	for _, flag := range flags {
@@ -306,7 +412,7 @@ func (b *Bot) IsPosOk(ptr point.Pointer, size BuildingSize, cells PathableCells,
 	}
 	if cells != Zero {
 		ps = b.GetPathablePoints(ptr, size, cells)
-		return b.CheckPoints(ps, IsPathable)
+		return ps != nil && b.CheckPoints(ps, IsPathable)
 	}
 	return true
 }
@@ -316,6 +422,9 @@ func (b *Bot) FindClosestPos(ptr point.Pointer, size BuildingSize, aid api.Abili
 	cells PathableCells, maxOffset, step int, flags ...CheckMap) point.Point {
 	pos := ptr.Point().Floor()
 	for offset := 0; offset <= maxOffset; offset += step {
+		// Positions of the same offset are checked by the game in one query
+		var ps point.Points
+		qb := b.NewQueryBatch()
 		for y := -float64(offset); y <= float64(offset); y++ {
 			for x := -float64(offset); x <= float64(offset); x++ {
 				if offset != 0 && math.Abs(x) != float64(offset) && math.Abs(y) != float64(offset) {
@@ -323,13 +432,25 @@ func (b *Bot) FindClosestPos(ptr point.Pointer, size BuildingSize, aid api.Abili
 				}
 				p := point.Pt(pos.X()+x, pos.Y()+y)
 				if b.IsPosOk(p, size, cells, flags...) {
-					if aid != 0 && !B.RequestPlacement(aid, pos, nil) {
-						continue
+					ps.Add(p)
+					if aid != 0 {
+						qb.AddPlacement(p, aid, p, nil)
 					}
-					return p
 				}
 			}
 		}
+		if ps.Empty() {
+			continue
+		}
+		if aid == 0 {
+			return ps[0]
+		}
+		qb.Send()
+		for _, p := range ps {
+			if qb.Placement(p) {
+				return p
+			}
+		}
 	}
 	return 0
 }
@@ -484,42 +605,24 @@ func (b *Bot) InitRamps() {
 }
 
 func (b *Bot) RequestPathing(p1, p2 point.Pointer) float64 {
-	rqps := []*api.RequestQueryPathing{{
-		Start: &api.RequestQueryPathing_StartPos{
-			StartPos: p1.Point().To2D(),
-		},
-		EndPos: p2.Point().To2D(),
-	}}
-	if resp, err := B.Client.Query(api.RequestQuery{Pathing: rqps}); err != nil || len(resp.Pathing) == 0 {
-		log.Error(err)
-		return 0
-	} else {
-		return float64(resp.Pathing[0].Distance)
-	}
+	qb := b.NewQueryBatch()
+	qb.AddPathing(0, p1, p2)
+	qb.Send()
+	return qb.Pathing(0)
 }
 
 func (b *Bot) RequestPlacement(ability api.AbilityID, pos point.Point, builder *Unit) bool {
-	var tag api.UnitTag
-	if builder != nil {
-		tag = builder.Tag
-	}
-	rps := []*api.RequestQueryBuildingPlacement{{
-		AbilityId:      ability,
-		TargetPos:      pos.To2D(),
-		PlacingUnitTag: tag,
-	}}
-	if resp, err := B.Client.Query(api.RequestQuery{Placements: rps}); err != nil || len(resp.Placements) == 0 {
-		log.Error(err)
-		return false
-	} else {
-		return resp.Placements[0].Result == api.ActionResult_Success
-	}
+	qb := b.NewQueryBatch()
+	qb.AddPlacement(0, ability, pos, builder)
+	qb.Send()
+	return qb.Placement(0)
 }
 
 func (b *Bot) FindExpansions() {
 	b.Locs.MyExps = nil
 	var expDists, enemyExpDists []float64
 	// Find expansions locations
+	qb := b.NewQueryBatch()
 	for _, uc := range b.CalculateExpansionLocations() {
 		center := uc.Center().CellCenter()
 		// Fill expansions locations list
@@ -527,15 +630,18 @@ func (b *Bot) FindExpansions() {
 			continue
 		}
 		b.Locs.MyExps = append(b.Locs.MyExps, center)
-		// Make pathing queries. One after another because seems that results can be shuffled (or not and it was me)
-		// From my base to that expansion
-		dist := b.RequestPathing(b.Locs.MyStart, center)
+		// From my base and from enemy base to that expansion
+		qb.AddPathing(pathingQuery{b.Locs.MyStart, center}, b.Locs.MyStart, center)
+		qb.AddPathing(pathingQuery{b.Locs.EnemyStart, center}, b.Locs.EnemyStart, center)
+	}
+	qb.Send()
+	for _, center := range b.Locs.MyExps {
+		dist := qb.Pathing(pathingQuery{b.Locs.MyStart, center})
 		if dist == 0 {
 			dist = b.Locs.MyStart.Dist(center) * 100
 		}
 		expDists = append(expDists, dist)
-		// From enemy base to the same expansion
-		dist = b.RequestPathing(b.Locs.EnemyStart, center)
+		dist = qb.Pathing(pathingQuery{b.Locs.EnemyStart, center})
 		if dist == 0 {
 			dist = b.Locs.EnemyStart.Dist(center) * 100
 		}
@@ -543,6 +649,7 @@ func (b *Bot) FindExpansions() {
 	}
 	b.Locs.EnemyExps = make(point.Points, len(b.Locs.MyExps))
 	copy(b.Locs.EnemyExps, b.Locs.MyExps)
+	b.InitBases(b.Locs.MyExps, expDists, enemyExpDists)
 
 	// Sort expansins locations by walking distance from base
 	b.Locs.MyExps = SortByOther(b.Locs.MyExps, expDists)
@@ -0,0 +1,53 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"testing"
+)
+
+func TestIsPosOkForRadius(t *testing.T) {
+	b := testNavBot()
+	pos := point.Pt(100, 100)
+	for y := 90.0; y < 110; y++ {
+		for x := 90.0; x < 110; x++ {
+			b.Grid.SetPathable(point.Pt(x, y), true)
+		}
+	}
+	buildings := map[BuildingSize]bool{S2x1: true, S2x2: true, S3x3: true, S5x3: true, S5x5: true}
+	for size := S2x1; size <= BreakableVerticalHuge; size++ {
+		for _, radius := range []float64{0, 0.375, 0.5, 0.75, 1, 1.25, 1.5, 2} {
+			cells := CellsForRadius(radius)
+			ok := b.IsPosOkForRadius(pos, size, radius)
+			if cells != Zero && !buildings[size] {
+				if ok {
+					t.Errorf("size %v, radius %v: position of unsupported size is accepted", size, radius)
+				}
+				continue
+			}
+			if !ok {
+				t.Errorf("size %v, radius %v: position on open ground is rejected", size, radius)
+			}
+			if cells == Zero {
+				continue
+			}
+			// Rightmost cell of the building in the row of its position
+			right := pos
+			for _, p := range b.GetBuildingPoints(pos, size) {
+				if p.Y() == pos.Y() && p.X() > right.X() {
+					right = p
+				}
+			}
+			near, far := right+point.Pt(float64(cells), 0), right+point.Pt(float64(cells)+1, 0)
+			b.Grid.SetPathable(far, false)
+			if !b.IsPosOkForRadius(pos, size, radius) {
+				t.Errorf("size %v, radius %v: obstacle at %v shouldn't matter", size, radius, far)
+			}
+			b.Grid.SetPathable(far, true)
+			b.Grid.SetPathable(near, false)
+			if b.IsPosOkForRadius(pos, size, radius) {
+				t.Errorf("size %v, radius %v: obstacle at %v is ignored", size, radius, near)
+			}
+			b.Grid.SetPathable(near, true)
+		}
+	}
+}
@@ -68,6 +68,25 @@ func BresenhamsLineDrawable(p0, p1 point.Point, grid *grid.Grid) bool {
 	}
 }
 
+// Cell is a waypoint if it is a convex corner of some obstacle
+func isWaypointCell(grid *grid.Grid, x, y int) bool {
+	if !grid.IsPathableFast(x, y) {
+		return false
+	}
+	for _, dy := range []int{-1, 1} {
+		for _, dx := range []int{-1, 1} {
+			if !grid.IsPathableFast(x+dx, y+dy) &&
+				grid.IsPathableFast(x+dx, y) &&
+				grid.IsPathableFast(x, y+dy) &&
+				// Diagonal lines optimization
+				(grid.IsPathableFast(x+2*dx, y) || grid.IsPathableFast(x, y+2*dy)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (b *Bot) FindWaypoints(wpm WaypointsMap, grid *grid.Grid) Waypoints {
 	waypoints := Waypoints{}
 	pa := b.Info.StartRaw.PlayableArea
@@ -77,20 +96,8 @@ func (b *Bot) FindWaypoints(wpm WaypointsMap, grid *grid.Grid) Waypoints {
 	p1y := int(pa.P1.Y)
 	for y := p0y; y <= p1y; y++ {
 		for x := p0x; x <= p1x; x++ {
-			if !grid.IsPathableFast(x, y) {
-				continue
-			}
-			for _, dy := range []int{-1, 1} {
-				for _, dx := range []int{-1, 1} {
-					if !grid.IsPathableFast(x+dx, y+dy) &&
-						grid.IsPathableFast(x+dx, y) &&
-						grid.IsPathableFast(x, y+dy) &&
-						// Diagonal lines optimization
-						(grid.IsPathableFast(x+2*dx, y) || grid.IsPathableFast(x, y+2*dy)) {
-						waypoints = append(waypoints, wpm.NewWaypoint(point.Pt(float64(x), float64(y))))
-						break
-					}
-				}
+			if isWaypointCell(grid, x, y) {
+				waypoints = append(waypoints, wpm.NewWaypoint(point.Pt(float64(x), float64(y))))
 			}
 		}
 	}
@@ -189,3 +196,330 @@ func NavPath(grid *grid.Grid, wpm WaypointsMap, fromPtr, toPtr point.Pointer) (p
 	}
 	return ps, dist
 }
+
+// Same as NavPath, but doesn't need waypoints: cells path is found by jump point search and then straightened
+func FastNavPath(g *grid.Grid, fromPtr, toPtr point.Pointer) (ps point.Points, dist float64) {
+	from := fromPtr.Point().Floor()
+	to := toPtr.Point().Floor()
+	if BresenhamsLineDrawable(from, to, g) {
+		return point.Points{from, to}, from.Dist(to)
+	}
+	withPathfinder(g, func(pf *grid.Pathfinder) {
+		pf.ResetCost()
+		path, _ := pf.Path(from, to)
+		ps = pf.Smooth(path)
+	})
+	for n := 1; n < ps.Len(); n++ {
+		dist += ps[n-1].Dist(ps[n])
+	}
+	return ps, dist
+}
+
+// Same as FastNavPath, but only cells where unit with the radius fits are used
+func RadiusNavPath(g *grid.Grid, fromPtr, toPtr point.Pointer, radius float64) (ps point.Points, dist float64) {
+	cm := B.Clearance(g)
+	from := cm.ClosestFitting(fromPtr, radius, clearanceSearchDist)
+	to := cm.ClosestFitting(toPtr, radius, clearanceSearchDist)
+	if from == 0 || to == 0 {
+		return nil, 0
+	}
+	withRadiusPathfinder(g, radius, func(pf *grid.Pathfinder) {
+		path, _ := pf.Path(from, to)
+		ps = pf.Smooth(path)
+	})
+	for n := 1; n < ps.Len(); n++ {
+		dist += ps[n-1].Dist(ps[n])
+	}
+	return ps, dist
+}
+
+const navMeshBlock = 8             // Changed cells are grouped into blocks of this size
+const navMeshMaxChangedShare = 0.1 // Mesh is rebuilt from scratch if more than this share of cells was changed
+const navMeshMaxOpenedShare = 0.25 // Also rebuilt if opened cells spread over this share of the map
+
+// Waypoints map that could be updated incrementally when only some cells of the grid were changed.
+// Map is exposed for NavPath, mesh itself uses its own copy of links because NavPath adds points to the Map
+type NavMesh struct {
+	Grid   *grid.Grid
+	Map    WaypointsMap
+	links  map[point.Point]point.Points // Waypoint -> visible waypoints
+	p0, p1 point.Point                  // Playable area
+}
+
+type navRect struct {
+	x0, y0, x1, y1 float64
+}
+
+func (b *Bot) NewNavMesh(grid *grid.Grid) *NavMesh {
+	pa := b.Info.StartRaw.PlayableArea
+	nm := &NavMesh{
+		Grid:  grid,
+		links: map[point.Point]point.Points{},
+		p0:    point.Pt(float64(pa.P0.X), float64(pa.P0.Y)),
+		p1:    point.Pt(float64(pa.P1.X), float64(pa.P1.Y)),
+	}
+	wpm := WaypointsMap{}
+	waypoints := b.FindWaypoints(wpm, grid)
+	for skip, from := range waypoints {
+		nm.links[from.Point] = nm.links[from.Point] // Waypoint without links is still a waypoint
+		for _, to := range waypoints[skip+1:] {
+			if BresenhamsLineDrawable(from.Point, to.Point, grid) {
+				nm.link(from.Point, to.Point)
+			}
+		}
+	}
+	nm.makeMap()
+	return nm
+}
+
+func (nm *NavMesh) link(from, to point.Point) {
+	nm.links[from] = append(nm.links[from], to)
+	nm.links[to] = append(nm.links[to], from)
+}
+
+func (nm *NavMesh) makeMap() {
+	nm.Map = make(WaypointsMap, len(nm.links))
+	wps := make(map[point.Point]*Waypoint, len(nm.links))
+	for p := range nm.links {
+		wps[p] = nm.Map.NewWaypoint(p)
+	}
+	for p, ps := range nm.links {
+		if len(ps) == 0 {
+			continue
+		}
+		neighbours := make(Waypoints, len(ps))
+		for n, to := range ps {
+			neighbours[n] = wps[to]
+		}
+		nm.Map[wps[p]] = neighbours
+	}
+}
+
+// States of cells in the new grid for line checks. Bitmap lookups are too slow for so many lines
+const (
+	cellBlocked byte = iota
+	cellPathable
+	cellOpened // Pathable now, but wasn't before
+)
+
+// Cells of the playable area which became unpathable and pathable in the new grid. Also states of all cells
+// of the playable area indexed as x + y*PathingSizeX
+func (nm *NavMesh) changedCells(grid *grid.Grid) (blocked, opened point.Points, cells []byte) {
+	cells = make([]byte, grid.PathingSizeX*grid.PathingSizeY)
+	for y := int(nm.p0.Y()); y <= int(nm.p1.Y()); y++ {
+		for x := int(nm.p0.X()); x <= int(nm.p1.X()); x++ {
+			was, is := nm.Grid.IsPathableFast(x, y), grid.IsPathableFast(x, y)
+			if was && !is {
+				blocked.Add(point.Pt(float64(x), float64(y)))
+			} else if !was && is {
+				opened.Add(point.Pt(float64(x), float64(y)))
+				cells[x+y*grid.PathingSizeX] = cellOpened
+			} else if is {
+				cells[x+y*grid.PathingSizeX] = cellPathable
+			}
+		}
+	}
+	return
+}
+
+// Blocks with changed cells expanded by one cell, because Bresenham's line may pass near the ideal segment.
+// The first rect is the bounding box of all others
+func changedRects(changed point.Points) []navRect {
+	if changed.Empty() {
+		return nil
+	}
+	blocks := map[point.Point]bool{}
+	for _, p := range changed {
+		blocks[point.Pt(math.Floor(p.X()/navMeshBlock), math.Floor(p.Y()/navMeshBlock))] = true
+	}
+	rects := []navRect{{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}}
+	for bp := range blocks {
+		x, y := bp.X()*navMeshBlock, bp.Y()*navMeshBlock
+		r := navRect{x - 1, y - 1, x + navMeshBlock, y + navMeshBlock}
+		rects = append(rects, r)
+		rects[0] = navRect{math.Min(rects[0].x0, r.x0), math.Min(rects[0].y0, r.y0),
+			math.Max(rects[0].x1, r.x1), math.Max(rects[0].y1, r.y1)}
+	}
+	return rects
+}
+
+// Liang-Barsky clipping: does segment touch the rect
+func (r navRect) crosses(p0, p1 point.Point) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := p1.X()-p0.X(), p1.Y()-p0.Y()
+	for _, c := range [4][2]float64{
+		{-dx, p0.X() - r.x0}, {dx, r.x1 - p0.X()}, {-dy, p0.Y() - r.y0}, {dy, r.y1 - p0.Y()},
+	} {
+		p, q := c[0], c[1]
+		if p == 0 {
+			if q < 0 {
+				return false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Bresenham's line depends on direction. Lines are always drawn from the point that is earlier in the grid
+// scan order like in FindWaypointsMap, so updated mesh is the same as rebuilt one
+func scanLineDrawable(p0, p1 point.Point, grid *grid.Grid) bool {
+	if p0.Y() > p1.Y() || (p0.Y() == p1.Y() && p0.X() > p1.X()) {
+		p0, p1 = p1, p0
+	}
+	return BresenhamsLineDrawable(p0, p1, grid)
+}
+
+// Same as scanLineDrawable over cell states from changedCells, but line should also pass through an opened cell
+func scanLineOpened(p0, p1 point.Point, cells []byte, width int) bool {
+	if p0.Y() > p1.Y() || (p0.Y() == p1.Y() && p0.X() > p1.X()) {
+		p0, p1 = p1, p0
+	}
+	x0, y0, x1, y1 := int(p0.X()), int(p0.Y()), int(p1.X()), int(p1.Y())
+	dx, dy := x1-x0, y1-y0 // dy >= 0 after the swap
+	sx := 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	err := dx - dy
+	opened := false
+	for {
+		c := cells[x0+y0*width]
+		if c == cellBlocked {
+			return false
+		}
+		opened = opened || c == cellOpened
+		if x0 == x1 && y0 == y1 {
+			return opened
+		}
+		e2 := 2 * err
+		if e2 > -dy {
+			err -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			err += dx
+			y0++
+		}
+	}
+}
+
+// Bounding box of the segment overlaps the rect
+func (r navRect) overlaps(p0, p1 point.Point) bool {
+	x0, x1, y0, y1 := p0.X(), p1.X(), p0.Y(), p1.Y()
+	if x0 > x1 {
+		x0, x1 = x1, x0
+	}
+	if y0 > y1 {
+		y0, y1 = y1, y0
+	}
+	return x1 >= r.x0 && x0 <= r.x1 && y1 >= r.y0 && y0 <= r.y1
+}
+
+func crossesAny(rects []navRect, p0, p1 point.Point) bool {
+	if len(rects) == 0 || !rects[0].overlaps(p0, p1) {
+		return false
+	}
+	for _, r := range rects[1:] {
+		if r.crosses(p0, p1) {
+			return true
+		}
+	}
+	return false
+}
+
+// New mesh for the changed grid. Only waypoints near changed cells and links that cross them are re-evaluated.
+// Old mesh is not modified, so its Map could be used by other goroutines meanwhile
+func (nm *NavMesh) Update(grid *grid.Grid) *NavMesh {
+	blocked, opened, cells := nm.changedCells(grid)
+	changed := append(blocked, opened...)
+	if changed.Empty() {
+		// Links are the same, but Map is made again because NavPath leaves its start and end points in it
+		res := &NavMesh{Grid: grid, links: nm.links, p0: nm.p0, p1: nm.p1}
+		res.makeMap()
+		return res
+	}
+	size := (nm.p1 - nm.p0) + 1 + 1i
+	if float64(changed.Len()) > size.X()*size.Y()*navMeshMaxChangedShare {
+		return B.NewNavMesh(grid)
+	}
+	blockedRects := changedRects(blocked)
+	openedRects := changedRects(opened)
+	if r := openedRects; r != nil && (r[0].x1-r[0].x0)*(r[0].y1-r[0].y0) > size.X()*size.Y()*navMeshMaxOpenedShare {
+		return B.NewNavMesh(grid) // Almost every pair of waypoints should be checked
+	}
+
+	// Waypoint status depends on cells up to 2 cells away
+	recheck := map[point.Point]bool{}
+	for _, c := range changed {
+		for y := c.Y() - 2; y <= c.Y()+2; y++ {
+			for x := c.X() - 2; x <= c.X()+2; x++ {
+				if x >= nm.p0.X() && y >= nm.p0.Y() && x <= nm.p1.X() && y <= nm.p1.Y() {
+					recheck[point.Pt(x, y)] = true
+				}
+			}
+		}
+	}
+	res := &NavMesh{Grid: grid, links: map[point.Point]point.Points{}, p0: nm.p0, p1: nm.p1}
+	var kept, added point.Points
+	removed := map[point.Point]bool{}
+	for p := range nm.links {
+		if !recheck[p] || isWaypointCell(grid, int(p.X()), int(p.Y())) {
+			res.links[p] = nil
+			kept.Add(p)
+		} else {
+			removed[p] = true
+		}
+	}
+	for p := range recheck {
+		if _, ok := res.links[p]; !ok && isWaypointCell(grid, int(p.X()), int(p.Y())) {
+			res.links[p] = nil
+			added.Add(p)
+		}
+	}
+
+	// Old links could be broken only by blocked cells
+	for _, from := range kept {
+		links := make(point.Points, 0, len(nm.links[from]))
+		for _, to := range nm.links[from] {
+			if len(removed) != 0 && removed[to] {
+				continue
+			}
+			if !crossesAny(blockedRects, from, to) || scanLineDrawable(from, to, grid) {
+				links = append(links, to)
+			}
+		}
+		res.links[from] = links
+	}
+	// New links between old waypoints could appear only through opened cells. Such lines were blocked before,
+	// so these waypoints weren't linked. Testing each opened rect costs more than walking the line itself
+	if openedRects != nil {
+		for i, from := range kept {
+			for _, to := range kept[i+1:] {
+				if openedRects[0].overlaps(from, to) && openedRects[0].crosses(from, to) &&
+					scanLineOpened(from, to, cells, grid.PathingSizeX) {
+					res.link(from, to)
+				}
+			}
+		}
+	}
+	// New waypoints are linked with all others
+	for i, from := range added {
+		for _, to := range append(kept, added[i+1:]...) {
+			if scanLineDrawable(from, to, grid) {
+				res.link(from, to)
+			}
+		}
+	}
+	res.makeMap()
+	return res
+}
@@ -0,0 +1,176 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"math/rand"
+	"testing"
+)
+
+const testMapSize = 200
+
+// Bot with a large map: random rectangular obstacles inside of the playable area with a border of 4 cells
+func testNavBot() *Bot {
+	rnd := rand.New(rand.NewSource(1))
+	pathable := make([]bool, testMapSize*testMapSize)
+	for y := 4; y < testMapSize-4; y++ {
+		for x := 4; x < testMapSize-4; x++ {
+			pathable[x+y*testMapSize] = true
+		}
+	}
+	for n := 0; n < 300; n++ {
+		x0, y0 := rnd.Intn(testMapSize), rnd.Intn(testMapSize)
+		w, h := 2+rnd.Intn(6), 2+rnd.Intn(6)
+		for y := y0; y < y0+h && y < testMapSize; y++ {
+			for x := x0; x < x0+w && x < testMapSize; x++ {
+				pathable[x+y*testMapSize] = false
+			}
+		}
+	}
+	for y := 50; y < 56; y++ {
+		for x := 30; x < 36; x++ {
+			pathable[x+y*testMapSize] = false // Rocks
+		}
+	}
+	data := make([]byte, testMapSize*testMapSize/8)
+	for addr, ok := range pathable {
+		if ok {
+			data[addr/8] |= 1 << (7 - addr%8)
+		}
+	}
+	size := &api.Size2DI{X: testMapSize, Y: testMapSize}
+	b := &Bot{}
+	B = b
+	b.Info = &api.ResponseGameInfo{StartRaw: &api.StartRaw{
+		MapSize:       size,
+		PathingGrid:   &api.ImageData{BitsPerPixel: 1, Size_: size, Data: data},
+		PlacementGrid: &api.ImageData{BitsPerPixel: 1, Size_: size, Data: make([]byte, len(data))},
+		TerrainHeight: &api.ImageData{BitsPerPixel: 8, Size_: size, Data: make([]byte, testMapSize*testMapSize)},
+		PlayableArea:  &api.RectangleI{P0: &api.PointI{X: 2, Y: 2}, P1: &api.PointI{X: testMapSize - 3, Y: testMapSize - 3}},
+	}}
+	b.Grid = grid.New(b.Info.StartRaw, &api.MapState{})
+	return b
+}
+
+// Copy of the grid with a new 3x3 building and (or) destroyed rocks
+func testChangedGrid(b *Bot, building, rocks bool) *grid.Grid {
+	g := grid.New(b.Grid.StartRaw, b.Grid.MapState)
+	if building {
+		for _, p := range b.GetBuildingPoints(point.Pt(100.5, 100.5), S3x3) {
+			g.SetPathable(p, false)
+		}
+	}
+	if rocks {
+		for y := 50.0; y < 56; y++ {
+			for x := 30.0; x < 36; x++ {
+				g.SetPathable(point.Pt(x, y), true)
+			}
+		}
+	}
+	return g
+}
+
+func linksSet(nm *NavMesh) map[[2]point.Point]bool {
+	set := map[[2]point.Point]bool{}
+	for from, ps := range nm.links {
+		set[[2]point.Point{from}] = true
+		for _, to := range ps {
+			set[[2]point.Point{from, to}] = true
+		}
+	}
+	return set
+}
+
+func TestNavMesh_Update(t *testing.T) {
+	for _, c := range []struct{ building, rocks bool }{{true, false}, {false, true}, {true, true}} {
+		b := testNavBot()
+		g := testChangedGrid(b, c.building, c.rocks)
+		updated := linksSet(b.NewNavMesh(b.Grid).Update(g))
+		rebuilt := linksSet(b.NewNavMesh(g))
+		if len(updated) != len(rebuilt) {
+			t.Fatalf("%+v: updated mesh has %d links and waypoints, rebuilt has %d", c, len(updated), len(rebuilt))
+		}
+		for link := range rebuilt {
+			if !updated[link] {
+				t.Fatalf("%+v: link %v is missing in the updated mesh", c, link)
+			}
+		}
+	}
+}
+
+func BenchmarkFindWaypointsMap(bm *testing.B) {
+	b := testNavBot()
+	g := testChangedGrid(b, true, false)
+	bm.ResetTimer()
+	for n := 0; n < bm.N; n++ {
+		b.FindWaypointsMap(g)
+	}
+}
+
+func benchmarkNavMeshUpdate(bm *testing.B, building, rocks bool) {
+	b := testNavBot()
+	nm := b.NewNavMesh(b.Grid)
+	g := testChangedGrid(b, building, rocks)
+	bm.ResetTimer()
+	for n := 0; n < bm.N; n++ {
+		nm.Update(g)
+	}
+}
+
+func BenchmarkNavMesh_UpdateBuilding(bm *testing.B) {
+	benchmarkNavMeshUpdate(bm, true, false)
+}
+
+func BenchmarkNavMesh_UpdateRocks(bm *testing.B) {
+	benchmarkNavMeshUpdate(bm, false, true)
+}
+
+// Copy of the grid where cells in range of a threat are not pathable, like in the safe grid
+func testThreatGrid(b *Bot, threat point.Point, radius float64) *grid.Grid {
+	g := grid.New(b.Grid.StartRaw, b.Grid.MapState)
+	for y := threat.Y() - radius; y <= threat.Y()+radius; y++ {
+		for x := threat.X() - radius; x <= threat.X()+radius; x++ {
+			if p := point.Pt(x, y); p.Dist(threat) <= radius {
+				g.SetPathable(p, false)
+			}
+		}
+	}
+	return g
+}
+
+func TestNavMesh_UpdateThreat(t *testing.T) {
+	b := testNavBot()
+	g0 := testThreatGrid(b, point.Pt(100, 100), 10)
+	g1 := testThreatGrid(b, point.Pt(104, 102), 10)
+	updated := linksSet(b.NewNavMesh(g0).Update(g1))
+	rebuilt := linksSet(b.NewNavMesh(g1))
+	if len(updated) != len(rebuilt) {
+		t.Fatalf("updated mesh has %d links and waypoints, rebuilt has %d", len(updated), len(rebuilt))
+	}
+	for link := range rebuilt {
+		if !updated[link] {
+			t.Fatalf("link %v is missing in the updated mesh", link)
+		}
+	}
+}
+
+// Threat moves a little between updates of the safe grid
+func BenchmarkNavMesh_UpdateThreat(bm *testing.B) {
+	b := testNavBot()
+	nm := b.NewNavMesh(testThreatGrid(b, point.Pt(100, 100), 10))
+	g := testThreatGrid(b, point.Pt(104, 102), 10)
+	bm.ResetTimer()
+	for n := 0; n < bm.N; n++ {
+		nm.Update(g)
+	}
+}
+
+func BenchmarkNewNavMesh_Threat(bm *testing.B) {
+	b := testNavBot()
+	g := testThreatGrid(b, point.Pt(104, 102), 10)
+	bm.ResetTimer()
+	for n := 0; n < bm.N; n++ {
+		b.NewNavMesh(g)
+	}
+}
@@ -194,7 +194,16 @@ func (b *Bot) FindHomeMineralsVector() {
 	b.Locs.MyStartMinVec = vec
 }
 
+// Navigation meshes are updated incrementally, only parts near changed cells are recalculated
+func updateNavMesh(nm *NavMesh, grid *grid.Grid) *NavMesh {
+	if nm == nil {
+		return B.NewNavMesh(grid)
+	}
+	return nm.Update(grid)
+}
+
 func (b *Bot) RenewPaths(stop <-chan struct{}) {
+	var navMesh, safeMesh, reaperMesh, reaperSafeMesh *NavMesh
 	for {
 		b.Grid.Lock()
 		navGrid := grid.New(b.Grid.StartRaw, b.Grid.MapState)
@@ -211,7 +220,8 @@ func (b *Bot) RenewPaths(stop <-chan struct{}) {
 		lastLoop := b.Loop
 
 		// s := time.Now()
-		b.WayMap = b.FindWaypointsMap(navGrid)
+		navMesh = updateNavMesh(navMesh, navGrid)
+		b.WayMap = navMesh.Map
 
 		if reapersExists {
 			pa := b.Info.StartRaw.PlayableArea
@@ -245,7 +255,8 @@ func (b *Bot) RenewPaths(stop <-chan struct{}) {
 				}
 			}
 			b.ReaperGrid = reaperGrid
-			b.ReaperWayMap = b.FindWaypointsMap(b.ReaperGrid)
+			reaperMesh = updateNavMesh(reaperMesh, reaperGrid)
+			b.ReaperWayMap = reaperMesh.Map
 		}
 
 		// s := time.Now()
@@ -259,12 +270,18 @@ func (b *Bot) RenewPaths(stop <-chan struct{}) {
 				}
 			}
 		}
-		// todo: add effects
+		zones := b.EffectZones
+		MarkEffectZones(safeGrid, zones)
+		if reapersExists {
+			MarkEffectZones(reaperSafeGrid, zones)
+		}
 		b.SafeGrid = safeGrid
-		b.SafeWayMap = b.FindWaypointsMap(b.SafeGrid)
+		safeMesh = updateNavMesh(safeMesh, safeGrid)
+		b.SafeWayMap = safeMesh.Map
 		if reapersExists {
 			b.ReaperSafeGrid = reaperSafeGrid
-			b.ReaperSafeWayMap = b.FindWaypointsMap(b.ReaperSafeGrid)
+			reaperSafeMesh = updateNavMesh(reaperSafeMesh, reaperSafeGrid)
+			b.ReaperSafeWayMap = reaperSafeMesh.Map
 		}
 		/*log.Info(time.Now().Sub(s))
 		wps := point.Points{}
@@ -0,0 +1,164 @@
+package scl
+
+import (
+	"bitbucket.org/aisee/minilog"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"sync"
+)
+
+type pathingQuery struct {
+	From, To point.Point
+}
+
+type placementQuery struct {
+	Ability api.AbilityID
+	Pos     point.Point
+	Builder api.UnitTag
+}
+
+// Results of the queries made during the current loop. Game state doesn't change within the loop
+var queryCache = struct {
+	sync.Mutex
+	loop       int
+	pathing    map[pathingQuery]float64
+	placements map[placementQuery]bool
+}{loop: -1}
+
+func renewQueryCache() {
+	if queryCache.loop != B.Loop || queryCache.pathing == nil {
+		queryCache.loop = B.Loop
+		queryCache.pathing = map[pathingQuery]float64{}
+		queryCache.placements = map[placementQuery]bool{}
+	}
+}
+
+// Pathing and placement checks that are sent to the game in one request. Results are accessed by the keys
+// given by the caller. Ex: qb.AddPlacement(n, ability.Build_Pylon, p, nil); qb.Send(); ok := qb.Placement(n)
+type QueryBatch struct {
+	pathing    map[interface{}]pathingQuery
+	placements map[interface{}]placementQuery
+	distances  map[pathingQuery]float64
+	results    map[placementQuery]bool
+}
+
+func (b *Bot) NewQueryBatch() *QueryBatch {
+	return &QueryBatch{
+		pathing:    map[interface{}]pathingQuery{},
+		placements: map[interface{}]placementQuery{},
+		distances:  map[pathingQuery]float64{},
+		results:    map[placementQuery]bool{},
+	}
+}
+
+func (qb *QueryBatch) AddPathing(key interface{}, from, to point.Pointer) {
+	qb.pathing[key] = pathingQuery{from.Point(), to.Point()}
+}
+
+func (qb *QueryBatch) AddPlacement(key interface{}, aid api.AbilityID, pos point.Point, builder *Unit) {
+	var tag api.UnitTag
+	if builder != nil {
+		tag = builder.Tag
+	}
+	qb.placements[key] = placementQuery{aid, pos, tag}
+}
+
+// Send all queries that are not cached yet in one request
+func (qb *QueryBatch) Send() {
+	queryCache.Lock()
+	defer queryCache.Unlock()
+	renewQueryCache()
+
+	var pqs []pathingQuery
+	var rqps []*api.RequestQueryPathing
+	for _, q := range qb.pathing {
+		if dist, ok := queryCache.pathing[q]; ok {
+			qb.distances[q] = dist
+			continue
+		}
+		if _, ok := qb.distances[q]; ok {
+			continue // Same query with the other key
+		}
+		qb.distances[q] = 0
+		pqs = append(pqs, q)
+		rqps = append(rqps, &api.RequestQueryPathing{
+			Start:  &api.RequestQueryPathing_StartPos{StartPos: q.From.To2D()},
+			EndPos: q.To.To2D(),
+		})
+	}
+	var bqs []placementQuery
+	var rps []*api.RequestQueryBuildingPlacement
+	for _, q := range qb.placements {
+		if ok, cached := queryCache.placements[q]; cached {
+			qb.results[q] = ok
+			continue
+		}
+		if _, ok := qb.results[q]; ok {
+			continue
+		}
+		qb.results[q] = false
+		bqs = append(bqs, q)
+		rps = append(rps, &api.RequestQueryBuildingPlacement{
+			AbilityId:      q.Ability,
+			TargetPos:      q.Pos.To2D(),
+			PlacingUnitTag: q.Builder,
+		})
+	}
+	if len(rqps) == 0 && len(rps) == 0 {
+		return
+	}
+
+	// Results are returned in the same order as queries
+	resp, err := B.Client.Query(api.RequestQuery{Pathing: rqps, Placements: rps})
+	if err != nil || len(resp.Pathing) != len(rqps) || len(resp.Placements) != len(rps) {
+		log.Error(err)
+		return
+	}
+	for n, q := range pqs {
+		qb.distances[q] = float64(resp.Pathing[n].Distance)
+		queryCache.pathing[q] = qb.distances[q]
+	}
+	for n, q := range bqs {
+		qb.results[q] = resp.Placements[n].Result == api.ActionResult_Success
+		queryCache.placements[q] = qb.results[q]
+	}
+}
+
+// Ground distance for the key. Returns 0 if there is no path or query wasn't sent
+func (qb *QueryBatch) Pathing(key interface{}) float64 {
+	return qb.distances[qb.pathing[key]]
+}
+
+// Building can be placed. Returns false if query wasn't sent
+func (qb *QueryBatch) Placement(key interface{}) bool {
+	return qb.results[qb.placements[key]]
+}
+
+// Ability that builds townhall of my race
+func (b *Bot) TownhallAbility() api.AbilityID {
+	switch b.MyRace() {
+	case api.Race_Protoss:
+		return ability.Build_Nexus
+	case api.Race_Zerg:
+		return ability.Build_Hatchery
+	}
+	return ability.Build_CommandCenter
+}
+
+// Bases where townhall can be placed right now. Checks are made in one query
+func (b *Bot) PlaceableBases(bases []*Base) []*Base {
+	aid := b.TownhallAbility()
+	qb := b.NewQueryBatch()
+	for n, base := range bases {
+		qb.AddPlacement(n, aid, base.Location, nil)
+	}
+	qb.Send()
+	var placeable []*Base
+	for n, base := range bases {
+		if qb.Placement(n) {
+			placeable = append(placeable, base)
+		}
+	}
+	return placeable
+}
@@ -0,0 +1,142 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+)
+
+// Picture of the map for offline analysis. Each cell is Scale x Scale pixels, top of the image is the top of the map.
+// Ex: B.NewMapImage(B.Grid, grid.LayerPathable, 4).DrawRamps(B.Ramps.All, White).Save("map.png")
+type MapImage struct {
+	*image.RGBA
+	Scale  int
+	Width  int // In cells
+	Height int
+}
+
+// Not premultiplied color, so it could be translucent
+func nrgba(c api.Color, alpha uint8) color.NRGBA {
+	return color.NRGBA{R: uint8(c.R), G: uint8(c.G), B: uint8(c.B), A: alpha}
+}
+
+// Image with grayscale layer of the grid as a background
+func (b *Bot) NewMapImage(g *grid.Grid, layer grid.Layer, scale int) *MapImage {
+	mi := &MapImage{Scale: scale, Width: g.PathingSizeX, Height: g.PathingSizeY}
+	mi.RGBA = image.NewRGBA(image.Rect(0, 0, mi.Width*scale, mi.Height*scale))
+	src := g.Image(layer)
+	for y := 0; y < mi.Height; y++ {
+		for x := 0; x < mi.Width; x++ {
+			v := src.GrayAt(x, y).Y
+			draw.Draw(mi.RGBA, image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale),
+				image.NewUniform(color.RGBA{R: v, G: v, B: v, A: 255}), image.Point{}, draw.Src)
+		}
+	}
+	return mi
+}
+
+// Pixel coordinates of the point
+func (mi *MapImage) pixel(p point.Point) (int, int) {
+	return int(p.X() * float64(mi.Scale)), int((float64(mi.Height) - p.Y()) * float64(mi.Scale))
+}
+
+func (mi *MapImage) fillCell(p point.Point, c color.Color) {
+	x, y := int(p.X()), mi.Height-1-int(p.Y())
+	draw.Draw(mi.RGBA, image.Rect(x*mi.Scale, y*mi.Scale, (x+1)*mi.Scale, (y+1)*mi.Scale),
+		image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// Tint cells where the layer is set. Height layer tints proportionally to the height
+func (mi *MapImage) DrawLayer(g *grid.Grid, layer grid.Layer, c api.Color) *MapImage {
+	for y := 0; y < mi.Height; y++ {
+		for x := 0; x < mi.Width; x++ {
+			p := point.Pt(float64(x), float64(y))
+			if v := g.LayerAt(layer, p); v != 0 {
+				mi.fillCell(p, nrgba(c, v/2))
+			}
+		}
+	}
+	return mi
+}
+
+// Same colors as DebugSafeGrid: yellow - pathable but not safe, blue - safe but not pathable, red - neither
+func (mi *MapImage) DrawSafeGrid(normal, safe *grid.Grid) *MapImage {
+	for y := 0; y < mi.Height; y++ {
+		for x := 0; x < mi.Width; x++ {
+			p := point.Pt(float64(x), float64(y))
+			pathable := normal.IsPathable(p)
+			isSafe := safe.IsPathable(p)
+			switch {
+			case !isSafe && !pathable:
+				mi.fillCell(p, nrgba(Red, 128))
+			case !pathable:
+				mi.fillCell(p, nrgba(Blue, 128))
+			case !isSafe:
+				mi.fillCell(p, nrgba(Yellow, 128))
+			}
+		}
+	}
+	return mi
+}
+
+func (mi *MapImage) DrawCells(ps point.Points, c api.Color) *MapImage {
+	for _, p := range ps {
+		mi.fillCell(p, nrgba(c, 255))
+	}
+	return mi
+}
+
+func (mi *MapImage) DrawLines(lines point.Lines, c api.Color) *MapImage {
+	for _, l := range lines {
+		x0, y0 := mi.pixel(l.A)
+		x1, y1 := mi.pixel(l.B)
+		steps := int(math.Max(math.Abs(float64(x1-x0)), math.Abs(float64(y1-y0))))
+		for n := 0; n <= steps; n++ {
+			k := 1.0
+			if steps != 0 {
+				k = float64(n) / float64(steps)
+			}
+			mi.Set(x0+int(math.Round(float64(x1-x0)*k)), y0+int(math.Round(float64(y1-y0)*k)), nrgba(c, 255))
+		}
+	}
+	return mi
+}
+
+// Same as DebugWayMap: waypoints and optionally links between them. Waypoints are cells, so lines connect centers
+func (mi *MapImage) DrawWayMap(wpm WaypointsMap, showLines bool, c api.Color) *MapImage {
+	lines := point.Lines{}
+	for wp, ns := range wpm {
+		mi.fillCell(wp.Point, nrgba(c, 255))
+		if showLines {
+			for _, n := range ns {
+				lines.Add(point.Line{A: wp.Point.CellCenter(), B: n.Point.CellCenter()})
+			}
+		}
+	}
+	return mi.DrawLines(lines, c)
+}
+
+func (mi *MapImage) DrawRamps(ramps []Ramp, c api.Color) *MapImage {
+	for _, ramp := range ramps {
+		mi.fillCell(ramp.Top, nrgba(c, 255))
+	}
+	return mi
+}
+
+func (mi *MapImage) Save(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, mi.RGBA); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,38 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"image/color"
+	"testing"
+)
+
+func TestMapImage_DrawSafeGrid(t *testing.T) {
+	b := testNavBot()
+	normal := grid.New(b.Grid.StartRaw, b.Grid.MapState)
+	normal.SetPathable(point.Pt(10, 10), true)
+	normal.SetPathable(point.Pt(11, 10), false)
+	normal.SetPathable(point.Pt(12, 10), false)
+	safe := grid.New(normal.StartRaw, normal.MapState)
+	safe.SetPathable(point.Pt(10, 10), false)
+	safe.SetPathable(point.Pt(11, 10), true)
+
+	mi := b.NewMapImage(normal, grid.LayerPathable, 1).DrawSafeGrid(normal, safe).
+		DrawCells(point.Points{point.Pt(13, 10)}, Green)
+	for _, c := range []struct {
+		x    float64
+		want color.RGBA
+	}{
+		{10, color.RGBA{R: 243, G: 216, B: 133, A: 255}}, // Yellow over pathable (white)
+		{11, color.RGBA{R: 6, G: 25, B: 116, A: 255}},    // Blue over unpathable (black)
+		{12, color.RGBA{R: 128, G: 0, B: 22, A: 255}},    // Red over unpathable
+		{13, color.RGBA{R: 1, G: 255, B: 48, A: 255}},    // Opaque
+	} {
+		got := mi.RGBAAt(int(c.x), mi.Height-1-10)
+		for _, d := range [][2]uint8{{got.R, c.want.R}, {got.G, c.want.G}, {got.B, c.want.B}, {got.A, c.want.A}} {
+			if diff := int(d[0]) - int(d[1]); diff > 1 || diff < -1 { // Rounding
+				t.Fatalf("cell %v: got %v, want %v", c.x, got, c.want)
+			}
+		}
+	}
+}
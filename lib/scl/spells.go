@@ -0,0 +1,197 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+	"github.com/aiseeq/s2l/protocol/enums/buff"
+	"github.com/aiseeq/s2l/protocol/enums/effect"
+	"math"
+)
+
+const spellCastGap = 1 // Caster can make a step towards the target before casting
+
+type Spell struct {
+	Ability      api.AbilityID
+	Radius       float64      // Area of effect
+	MinRadius    float64      // Units closer than this to the center are not hit (Siege Tank minimal range)
+	Range        float64      // Cast range. 0 - spell is centered on the caster
+	Energy       float32      // Energy cost
+	Filter       Filter       // Which units are affected. nil - all
+	Value        Compare      // Value of the affected unit. nil - CmpTotalScore
+	FriendlyFire float64      // Multiplier for own units value. 0 - spell doesn't hurt own units
+	MinScore     float64      // Don't cast if score is lower
+	Effect       api.EffectID // Don't cast where this effect already is
+}
+
+// Defaults for area abilities
+var Spells = map[api.AbilityID]Spell{
+	ability.Effect_PsiStorm: {
+		Ability: ability.Effect_PsiStorm, Radius: 1.5, Range: 9, Energy: 75, Filter: NotStructure,
+		FriendlyFire: 1, MinScore: 1500, Effect: effect.PsiStorm,
+	},
+	ability.Effect_EMP: {
+		Ability: ability.Effect_EMP, Radius: 1.5, Range: 10, Energy: 75,
+		Filter: func(unit *Unit) bool { return unit.Shield > 0 || unit.Energy > 0 },
+		Value:  func(unit *Unit) float64 { return float64(unit.Shield + unit.Energy) },
+		// Own casters lose energy too
+		FriendlyFire: 1, MinScore: 150,
+	},
+	ability.Effect_FungalGrowth: {
+		Ability: ability.Effect_FungalGrowth, Radius: 2.25, Range: 10, Energy: 75,
+		Filter:   func(unit *Unit) bool { return !unit.IsStructure() && !unit.HasBuff(buff.FungalGrowth) },
+		MinScore: 1000,
+	},
+	ability.Effect_KD8Charge: {
+		Ability: ability.Effect_KD8Charge, Radius: KD8Radius, Range: 5, Filter: Ground, FriendlyFire: 1, MinScore: 400,
+	},
+	ability.Effect_CorrosiveBile: {
+		Ability: ability.Effect_CorrosiveBile, Radius: 0.5, Range: 9, FriendlyFire: 1, MinScore: 300,
+		Effect: effect.CorrosiveBile,
+	},
+	// Siege if there are enough ground targets in range. Splash is small, so friendly fire is not counted
+	ability.Morph_SiegeMode: {
+		Ability: ability.Morph_SiegeMode, Radius: 13, MinRadius: 2, Filter: Ground, MinScore: 300,
+	},
+}
+
+// Caster has the ability (not on cooldown) and enough energy
+func (u *Unit) CanCast(s Spell) bool {
+	return u.HasAbility(s.Ability) && u.Energy >= s.Energy
+}
+
+func (s Spell) hits(pos point.Point, unit *Unit) bool {
+	dist := unit.Dist(pos) - float64(unit.Radius)
+	if dist > s.Radius || unit.Dist(pos) < s.MinRadius {
+		return false
+	}
+	return s.Filter == nil || s.Filter(unit)
+}
+
+func (s Spell) value(unit *Unit) float64 {
+	if s.Value == nil {
+		return CmpTotalScore(unit)
+	}
+	return s.Value(unit)
+}
+
+// Enemy value hit by the spell at pos minus value of own units hit (multiplied by FriendlyFire)
+func (s Spell) Score(pos point.Point, enemies, allies Units) float64 {
+	if s.Effect != 0 {
+		for _, e := range B.Obs.RawData.Effects {
+			if e.EffectId != s.Effect {
+				continue
+			}
+			for _, p := range e.Pos {
+				if pos.IsCloserThan(s.Radius, point.Pt2(p)) {
+					return 0 // Don't waste the spell
+				}
+			}
+		}
+	}
+	score := 0.0
+	for _, unit := range enemies {
+		if s.hits(pos, unit) {
+			score += s.value(unit)
+		}
+	}
+	if s.FriendlyFire > 0 {
+		for _, unit := range allies {
+			if s.hits(pos, unit) {
+				score -= s.value(unit) * s.FriendlyFire
+			}
+		}
+	}
+	return score
+}
+
+// Points worth checking: enemy positions and centers of enemy groups that fit into the spell radius
+func (s Spell) candidates(enemies Units) point.Points {
+	var ps point.Points
+	for _, unit := range enemies {
+		ps.Add(unit.Point())
+	}
+	for _, c := range ClusterUnits(enemies, s.Radius) {
+		if c.Count() > 1 {
+			ps.Add(c.Center())
+		}
+	}
+	return ps
+}
+
+// Best point to cast the spell and its score. Returns 0 if caster can't cast it or there is no point with score
+// higher than MinScore
+func (u *Unit) SpellTarget(s Spell, enemies Units) (point.Point, float64) {
+	if !u.CanCast(s) {
+		return 0, 0
+	}
+	enemies = enemies.Filter(func(unit *Unit) bool { return s.Filter == nil || s.Filter(unit) })
+	if enemies.Empty() {
+		return 0, 0
+	}
+	allies := B.Units.MyAll
+
+	var candidates point.Points
+	if s.Range == 0 {
+		candidates = point.Points{u.Point()}
+	} else {
+		castRange := s.Range + float64(u.Radius) + spellCastGap
+		for _, p := range s.candidates(enemies) {
+			if u.IsCloserThan(castRange, p) {
+				candidates.Add(p)
+			}
+		}
+	}
+
+	var best point.Point
+	bestScore := math.Inf(-1)
+	for _, p := range candidates {
+		if score := s.Score(p, enemies, allies); score > bestScore {
+			best = p
+			bestScore = score
+		}
+	}
+	if best == 0 || bestScore < s.MinScore {
+		return 0, 0
+	}
+	return best, bestScore
+}
+
+// Cast the ability with its default parameters if there is a good target. Returns true if command was sent
+func (u *Unit) CastSpell(aid api.AbilityID, enemies Units) bool {
+	s, ok := Spells[aid]
+	if !ok {
+		return false
+	}
+	pos, _ := u.SpellTarget(s, enemies)
+	if pos == 0 {
+		return false
+	}
+	if s.Range == 0 {
+		u.Command(s.Ability)
+	} else {
+		u.CommandPos(s.Ability, pos)
+	}
+	return true
+}
+
+// Point where the tank should siege to hit most of enemies without getting too close to them. Returns 0 if
+// there are no good positions within maxDist from the tank
+func (u *Unit) SiegePos(enemies Units, maxDist float64) point.Point {
+	s := Spells[ability.Morph_SiegeMode]
+	enemies = enemies.Filter(s.Filter)
+	var best point.Point
+	bestScore := s.MinScore
+	for _, c := range s.candidates(enemies) {
+		// Stay on the max range from the enemy
+		p := c.Towards(u, s.Radius-1)
+		if !u.IsCloserThan(maxDist, p) || !B.Grid.IsPathable(p) {
+			continue
+		}
+		if score := s.Score(p, enemies, nil); score > bestScore {
+			best = p
+			bestScore = score
+		}
+	}
+	return best
+}
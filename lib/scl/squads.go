@@ -0,0 +1,290 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/ability"
+)
+
+type SquadRole int
+type SquadState int
+
+const (
+	RoleArmy SquadRole = iota + 1
+	RoleHarass
+	RoleDefense
+	RoleScout
+	RoleWorker
+)
+const (
+	StateGather SquadState = iota + 1
+	StateMove
+	StateEngage
+	StateRetreat
+)
+
+const squadEngageRange = 14    // Max possible unit range (Tempest)
+const squadGatherRadius = 6    // Squad is gathered if all units are closer than this to the center
+const squadDefenseRadius = 20  // Defense squads don't chase enemies further than this from the gather point
+const squadLostLoops = 45      // Units not seen for this time are considered dead (~2 sec)
+const squadRetreatRatio = 0.75 // Retreat if our score is less than enemy score multiplied by this
+const squadEngageRatio = 1.25  // Engage again only when we are this much stronger
+
+// Should squad take this unit as reinforcement
+type ReinforceRule func(u *Unit) bool
+
+type Squad struct {
+	Name      string
+	Role      SquadRole
+	State     SquadState
+	StateLoop int // Loop when state was changed
+	Target    point.Point
+	Gather    point.Point
+	Formation Formation // Used to move in StateMove. Units move as blob if it is 0
+	Rule      ReinforceRule
+	Limit     int // Max units in squad, 0 - no limit
+	MinSize   int // Squad waits on the gather point until it has this many units
+	Tags      Tags
+	Units     Units // Alive units of the squad. Refreshed every frame
+}
+
+type Squads struct {
+	List     []*Squad // In priority order for reinforcements
+	units    map[api.UnitTag]*Squad
+	lastSeen map[api.UnitTag]int
+}
+
+func NewSquads() *Squads {
+	return &Squads{
+		units:    map[api.UnitTag]*Squad{},
+		lastSeen: map[api.UnitTag]int{},
+	}
+}
+
+// Create a new squad. Squads created earlier get reinforcements first
+func (ss *Squads) New(name string, role SquadRole, rule ReinforceRule) *Squad {
+	s := &Squad{Name: name, Role: role, State: StateGather, StateLoop: B.Loop, Rule: rule}
+	ss.List = append(ss.List, s)
+	return s
+}
+
+func (ss *Squads) Get(name string) *Squad {
+	for _, s := range ss.List {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Squad of the unit or nil
+func (ss *Squads) Of(u *Unit) *Squad {
+	return ss.units[u.Tag]
+}
+
+// Move units into the squad. Unit is always in one squad only
+func (ss *Squads) Add(s *Squad, units ...*Unit) {
+	for _, u := range units {
+		if old := ss.units[u.Tag]; old != nil {
+			if old == s {
+				continue
+			}
+			old.Tags.Remove(u.Tag)
+			old.Units.RemoveTag(u.Tag)
+		}
+		s.Tags.Add(u.Tag)
+		s.Units.Add(u)
+		ss.units[u.Tag] = s
+		ss.lastSeen[u.Tag] = B.Loop
+	}
+}
+
+func (ss *Squads) Remove(units ...*Unit) {
+	for _, u := range units {
+		ss.removeTag(u.Tag)
+	}
+}
+
+func (ss *Squads) removeTag(tag api.UnitTag) {
+	if s := ss.units[tag]; s != nil {
+		s.Tags.Remove(tag)
+		s.Units.RemoveTag(tag)
+	}
+	delete(ss.units, tag)
+	delete(ss.lastSeen, tag)
+}
+
+// Disband the squad, its units become free
+func (ss *Squads) Delete(s *Squad) {
+	for _, tag := range append(Tags{}, s.Tags...) {
+		ss.removeTag(tag)
+	}
+	for n, sq := range ss.List {
+		if sq == s {
+			ss.List = append(ss.List[:n], ss.List[n+1:]...)
+			break
+		}
+	}
+}
+
+// Put unit into the first squad which rule accepts it and that is not full. Returns nil if there is no such squad
+func (ss *Squads) Assign(u *Unit) *Squad {
+	if s := ss.units[u.Tag]; s != nil {
+		return s
+	}
+	for _, s := range ss.List {
+		if s.Rule == nil || !s.Rule(u) || (s.Limit > 0 && s.Tags.Len() >= s.Limit) {
+			continue
+		}
+		ss.Add(s, u)
+		return s
+	}
+	return nil
+}
+
+func (ss *Squads) ClearUnits() {
+	for _, s := range ss.List {
+		s.Units = nil
+	}
+}
+
+// Add unit info to the corresponding squad
+func (ss *Squads) Fill(u *Unit) {
+	if s := ss.units[u.Tag]; s != nil {
+		s.Units.Add(u)
+		ss.lastSeen[u.Tag] = B.Loop
+	}
+}
+
+// Remove units that were not seen for a while. Passengers of own bunkers and transports are not visible,
+// so they are kept in squads. Workers stay inside of refineries for less than squadLostLoops
+func (ss *Squads) RemoveLost() {
+	for _, u := range B.Units.MyAll {
+		for _, p := range u.Passengers {
+			if _, ok := ss.lastSeen[p.Tag]; ok {
+				ss.lastSeen[p.Tag] = B.Loop
+			}
+		}
+	}
+	for tag, loop := range ss.lastSeen {
+		if loop+squadLostLoops < B.Loop {
+			ss.removeTag(tag)
+		}
+	}
+}
+
+// Update states and send orders for all squads
+func (ss *Squads) Step() {
+	for _, s := range ss.List {
+		s.Step()
+	}
+}
+
+func (s *Squad) SetState(state SquadState) {
+	if s.State != state {
+		s.State = state
+		s.StateLoop = B.Loop
+	}
+}
+
+func (s *Squad) Center() point.Point {
+	return s.Units.Center()
+}
+
+func (s *Squad) IsGathered() bool {
+	center := s.Center()
+	return s.Units.FurtherThan(squadGatherRadius, center).Empty()
+}
+
+// Enemies that squad should fight
+func (s *Squad) Enemies() Units {
+	center := s.Center()
+	enemies := B.Enemies.AllReady.CloserThan(squadEngageRange, center)
+	if s.Role == RoleDefense && s.Gather != 0 {
+		enemies = enemies.CloserThan(squadDefenseRadius, s.Gather)
+	}
+	return enemies.Filter(func(unit *Unit) bool {
+		return unit.IsArmed() || unit.IsDefensive() || s.Role == RoleHarass && unit.IsWorker()
+	})
+}
+
+// Compare strength of the squad with strength of enemies near it. Returns our score / their score
+func (s *Squad) StrengthRatio(enemies Units) float64 {
+	theirs := enemies.Sum(CmpTotalScore)
+	if theirs == 0 {
+		return squadEngageRatio * 2
+	}
+	return s.Units.Sum(CmpTotalScore) / theirs
+}
+
+// Change squad state depending on situation
+func (s *Squad) UpdateState() {
+	if s.Units.Empty() {
+		s.SetState(StateGather)
+		return
+	}
+	enemies := s.Enemies()
+	ratio := s.StrengthRatio(enemies)
+	switch {
+	case enemies.Exists() && s.Role == RoleScout:
+		s.SetState(StateRetreat)
+	case enemies.Exists() && s.State == StateRetreat && ratio < squadEngageRatio:
+		// Keep retreating until we are much stronger, so squad won't switch states every frame
+	case enemies.Exists() && ratio < squadRetreatRatio && s.Role != RoleDefense:
+		s.SetState(StateRetreat)
+	case enemies.Exists():
+		s.SetState(StateEngage)
+	case s.Units.Len() < s.MinSize || (s.State != StateMove && s.Gather != 0 && !s.IsGathered()):
+		s.SetState(StateGather)
+	case s.Target != 0:
+		s.SetState(StateMove)
+	default:
+		s.SetState(StateGather)
+	}
+}
+
+// Send orders for current state
+func (s *Squad) Act() {
+	if s.Units.Empty() || s.Role == RoleWorker {
+		return // Workers are handled by mining logic
+	}
+	switch s.State {
+	case StateGather:
+		if s.Gather != 0 {
+			s.Units.FurtherThan(2, s.Gather).CommandPos(ability.Move, s.Gather)
+		}
+	case StateMove:
+		if s.Formation != 0 {
+			s.Units.CommandFormation(s.Formation, s.Target)
+		} else {
+			s.Units.CommandPos(ability.Move, s.Target)
+		}
+	case StateEngage:
+		enemies := s.Enemies()
+		if s.Role == RoleHarass {
+			s.Units.Kite(enemies)
+		} else {
+			s.Units.AttackFocused(enemies)
+		}
+	case StateRetreat:
+		pos := s.Gather
+		if pos == 0 {
+			pos = B.Locs.MyStart
+		}
+		enemies := s.Enemies()
+		for _, u := range s.Units {
+			if u.IsFlying {
+				p, _ := u.AirEvade(enemies, 2, pos)
+				u.CommandPos(ability.Move, p)
+			} else {
+				u.GroundFallback(pos, false)
+			}
+		}
+	}
+}
+
+// Update state and send orders
+func (s *Squad) Step() {
+	s.UpdateState()
+	s.Act()
+}
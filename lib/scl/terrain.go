@@ -0,0 +1,500 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/grid"
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"math"
+	"sort"
+)
+
+const terrainMinRegionArea = 100    // Smaller regions are merged into neighbours
+const terrainMinRegionClearance = 4 // Regions narrower than this are corridors, they are merged into neighbours
+const terrainChokeRatio = 0.6       // Passage is a choke if it is narrower than this part of the smaller region
+const terrainChokeClusterDist = 3   // Frontier cells closer than this belong to the same choke
+const terrainBlockedCheckRadius = 6 // Extra radius around choke to check if rocks block it
+
+type RegionID int
+
+type Region struct {
+	ID     RegionID
+	Area   int         // Number of pathable cells
+	Center point.Point // Most open point of the region
+	Height float64
+	Chokes []*Choke
+}
+
+type Choke struct {
+	ID      int
+	Regions [2]*Region
+	Center  point.Point
+	Width   float64
+	Points  point.Points // Cells on the border between regions
+	Ramp    bool
+	Rocks   []api.UnitTag // Destructibles that narrow or block the choke
+	Blocked bool          // Choke is not passable until its rocks are destroyed
+}
+
+type Terrain struct {
+	Width, Height int
+	Regions       []*Region // Index is RegionID - 1
+	Chokes        []*Choke
+	RockChokes    map[api.UnitTag][]*Choke // Which connections each destructible affects
+
+	clearance []float64
+	labels    []RegionID
+	rocks     map[point.Point]api.UnitTag
+}
+
+type terrainBasin struct {
+	parent    int
+	area      int
+	clearance float64 // Max clearance in the basin
+	seed      int     // Cell with max clearance
+}
+
+type terrainFrontier struct {
+	cell int
+	a, b int
+}
+
+func (t *Terrain) addr(p point.Point) int {
+	x, y := int(p.X()), int(p.Y())
+	if x < 0 || y < 0 || x >= t.Width || y >= t.Height {
+		return -1
+	}
+	return y*t.Width + x
+}
+
+func (t *Terrain) cell(addr int) point.Point {
+	return point.Pt(float64(addr%t.Width), float64(addr/t.Width))
+}
+
+// Region containing the point or nil for unpathable cells
+func (t *Terrain) RegionAt(ptr point.Pointer) *Region {
+	addr := t.addr(ptr.Point())
+	if addr == -1 || t.labels[addr] == 0 {
+		return nil
+	}
+	return t.Regions[t.labels[addr]-1]
+}
+
+// Distance from the cell to the closest obstacle
+func (t *Terrain) ClearanceAt(ptr point.Pointer) float64 {
+	if addr := t.addr(ptr.Point()); addr != -1 {
+		return t.clearance[addr]
+	}
+	return 0
+}
+
+// Choke can be passed: it was not blocked or all its rocks are destroyed
+func (c *Choke) IsOpen() bool {
+	if !c.Blocked {
+		return true
+	}
+	for _, tag := range c.Rocks {
+		if B.Units.ByTag[tag] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Region on the other side of the choke
+func (c *Choke) Other(r *Region) *Region {
+	if c.Regions[0] == r {
+		return c.Regions[1]
+	}
+	return c.Regions[0]
+}
+
+// Regions connected to this one by chokes
+func (r *Region) Neighbours(openOnly bool) []*Region {
+	var rs []*Region
+	for _, c := range r.Chokes {
+		if !openOnly || c.IsOpen() {
+			rs = append(rs, c.Other(r))
+		}
+	}
+	return rs
+}
+
+// Chokes that should be passed to get from one point to another. Closed chokes are skipped.
+// Returns nil if points are in the same region or there is no connection
+func (t *Terrain) ChokePath(fromPtr, toPtr point.Pointer) []*Choke {
+	from, to := t.RegionAt(fromPtr), t.RegionAt(toPtr)
+	if from == nil || to == nil || from == to {
+		return nil
+	}
+	// Dijkstra over regions graph. Distance is measured between choke centers
+	dist := map[*Region]float64{from: 0}
+	pos := map[*Region]point.Point{from: fromPtr.Point()}
+	via := map[*Region]*Choke{}
+	done := map[*Region]bool{}
+	for {
+		var cur *Region
+		for r, d := range dist {
+			if !done[r] && (cur == nil || d < dist[cur]) {
+				cur = r
+			}
+		}
+		if cur == nil {
+			return nil
+		}
+		if cur == to {
+			break
+		}
+		done[cur] = true
+		for _, c := range cur.Chokes {
+			next := c.Other(cur)
+			if done[next] || !c.IsOpen() {
+				continue
+			}
+			d := dist[cur] + pos[cur].Dist(c.Center)
+			if old, ok := dist[next]; !ok || d < old {
+				dist[next] = d
+				pos[next] = c.Center
+				via[next] = c
+			}
+		}
+	}
+	var path []*Choke
+	for r := to; r != from; r = via[r].Other(r) {
+		path = append([]*Choke{via[r]}, path...)
+	}
+	return path
+}
+
+// Choke of the natural expansion region that leads out of the base (not into the main)
+func (b *Bot) NaturalChoke() *Choke {
+	if b.Terrain == nil || b.Locs.MyExps.Empty() {
+		return nil
+	}
+	main := b.Terrain.RegionAt(b.Locs.MyStart)
+	natural := b.Terrain.RegionAt(b.Locs.MyExps[0])
+	if natural == nil || natural == main {
+		return nil
+	}
+	var best *Choke
+	for _, c := range natural.Chokes {
+		if c.Other(natural) == main || !c.IsOpen() {
+			continue
+		}
+		// Choke used by the enemy to come to us
+		if best == nil || c.Center.Dist2(b.Locs.EnemyStart) < best.Center.Dist2(b.Locs.EnemyStart) {
+			best = c
+		}
+	}
+	return best
+}
+
+// Decompose pathable area into regions connected by chokes
+func (b *Bot) AnalyzeTerrain() *Terrain {
+	size := b.Info.StartRaw.MapSize
+	t := &Terrain{
+		Width:      int(size.X),
+		Height:     int(size.Y),
+		RockChokes: map[api.UnitTag][]*Choke{},
+		rocks:      map[point.Point]api.UnitTag{},
+	}
+	cells := t.Width * t.Height
+
+	// Terrain without buildings. Rocks are passable, minerals and geysers are not
+	raw := grid.New(b.Info.StartRaw, b.Obs.RawData.MapState)
+	pathable := make([]bool, cells)
+	for addr := range pathable {
+		pathable[addr] = raw.IsPathable(t.cell(addr))
+	}
+	for _, u := range b.Units.Neutral.All() {
+		if ps, unpathable := b.NeutralFootprint(u); unpathable {
+			for _, p := range ps {
+				if addr := t.addr(p); addr != -1 {
+					pathable[addr] = true
+					t.rocks[p] = u.Tag
+				}
+			}
+		}
+	}
+	for _, u := range b.Units.Minerals.All() {
+		ps, _ := b.NeutralFootprint(u)
+		for _, p := range ps {
+			if addr := t.addr(p); addr != -1 {
+				pathable[addr] = false
+			}
+		}
+	}
+	for _, u := range b.Units.Geysers.All() {
+		for _, p := range b.GetBuildingPoints(u, S3x3) {
+			if addr := t.addr(p); addr != -1 {
+				pathable[addr] = false
+			}
+		}
+	}
+
+	t.calcClearance(pathable)
+	frontiers, basins := t.watershed(pathable)
+	t.makeRegions(basins, b.Grid)
+	t.makeChokes(frontiers, basins, pathable, b.Grid)
+	return t
+}
+
+// Chamfer distance transform: distance from each pathable cell to the closest obstacle
+func (t *Terrain) calcClearance(pathable []bool) {
+	t.clearance = make([]float64, len(pathable))
+	for addr, ok := range pathable {
+		if ok {
+			t.clearance[addr] = math.Inf(1)
+		}
+	}
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= t.Width || y >= t.Height {
+			return 0 // Map border is an obstacle
+		}
+		return t.clearance[y*t.Width+x]
+	}
+	relax := func(x, y int, offsets [4][3]int) {
+		addr := y*t.Width + x
+		for _, o := range offsets {
+			cost := 1.0
+			if o[2] == 1 {
+				cost = math.Sqrt2
+			}
+			t.clearance[addr] = math.Min(t.clearance[addr], at(x+o[0], y+o[1])+cost)
+		}
+	}
+	forward := [4][3]int{{-1, 0, 0}, {-1, -1, 1}, {0, -1, 0}, {1, -1, 1}}
+	backward := [4][3]int{{1, 0, 0}, {1, 1, 1}, {0, 1, 0}, {-1, 1, 1}}
+	for y := 0; y < t.Height; y++ {
+		for x := 0; x < t.Width; x++ {
+			if pathable[y*t.Width+x] {
+				relax(x, y, forward)
+			}
+		}
+	}
+	for y := t.Height - 1; y >= 0; y-- {
+		for x := t.Width - 1; x >= 0; x-- {
+			if pathable[y*t.Width+x] {
+				relax(x, y, backward)
+			}
+		}
+	}
+}
+
+func findBasin(basins []terrainBasin, n int) int {
+	for basins[n].parent != n {
+		basins[n].parent = basins[basins[n].parent].parent
+		n = basins[n].parent
+	}
+	return n
+}
+
+// Flood cells from the most open ones. When two big basins meet in a narrow place, there is a choke
+func (t *Terrain) watershed(pathable []bool) ([]terrainFrontier, []terrainBasin) {
+	var order []int
+	for addr, ok := range pathable {
+		if ok {
+			order = append(order, addr)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return t.clearance[order[i]] > t.clearance[order[j]] })
+
+	cellBasin := make([]int, len(pathable))
+	for n := range cellBasin {
+		cellBasin[n] = -1
+	}
+	var basins []terrainBasin
+	var frontiers []terrainFrontier
+	for _, addr := range order {
+		d := t.clearance[addr]
+		roots := map[int]float64{} // basin -> max clearance of neighbour cells in it
+		for _, np := range t.cell(addr).Neighbours8(1) {
+			if naddr := t.addr(np); naddr != -1 && cellBasin[naddr] != -1 {
+				r := findBasin(basins, cellBasin[naddr])
+				roots[r] = math.Max(roots[r], t.clearance[naddr])
+			}
+		}
+		if len(roots) == 0 {
+			basins = append(basins, terrainBasin{parent: len(basins), clearance: d, seed: addr})
+			cellBasin[addr] = len(basins) - 1
+			basins[len(basins)-1].area++
+			continue
+		}
+		var rs []int
+		for r := range roots {
+			rs = append(rs, r)
+		}
+		// Basin of the most open neighbour takes the cell, so basins don't crawl along walls of each other.
+		// Sort also makes result independent of map iteration order
+		sort.Slice(rs, func(i, j int) bool {
+			if roots[rs[i]] != roots[rs[j]] {
+				return roots[rs[i]] > roots[rs[j]]
+			}
+			return rs[i] < rs[j]
+		})
+		main := rs[0]
+		for _, r := range rs[1:] {
+			r = findBasin(basins, r)
+			main = findBasin(basins, main)
+			if r == main {
+				continue
+			}
+			smaller := math.Min(basins[main].clearance, basins[r].clearance)
+			minArea := MinInt(basins[main].area, basins[r].area)
+			if smaller < terrainMinRegionClearance || minArea < terrainMinRegionArea || d >= smaller*terrainChokeRatio {
+				// Merge
+				basins[r].parent = main
+				basins[main].area += basins[r].area
+				if basins[r].clearance > basins[main].clearance {
+					basins[main].clearance = basins[r].clearance
+					basins[main].seed = basins[r].seed
+				}
+				continue
+			}
+			frontiers = append(frontiers, terrainFrontier{addr, main, r})
+		}
+		main = findBasin(basins, main)
+		cellBasin[addr] = main
+		basins[main].area++
+	}
+
+	// Store final region of each cell in labels for now, they are renumbered later
+	t.labels = make([]RegionID, len(pathable))
+	for addr, n := range cellBasin {
+		if n != -1 {
+			t.labels[addr] = RegionID(findBasin(basins, n) + 1)
+		}
+	}
+	return frontiers, basins
+}
+
+func (t *Terrain) makeRegions(basins []terrainBasin, g *grid.Grid) {
+	ids := map[RegionID]RegionID{} // basin -> region
+	heights := map[RegionID]float64{}
+	for addr, label := range t.labels {
+		if label == 0 {
+			continue
+		}
+		id, ok := ids[label]
+		if !ok {
+			id = RegionID(len(t.Regions) + 1)
+			ids[label] = id
+			basin := basins[label-1]
+			t.Regions = append(t.Regions, &Region{ID: id, Center: t.cell(basin.seed).CellCenter()})
+		}
+		t.labels[addr] = id
+		t.Regions[id-1].Area++
+		heights[id] += g.HeightAt(t.cell(addr))
+	}
+	for _, r := range t.Regions {
+		r.Height = heights[r.ID] / float64(r.Area)
+	}
+}
+
+func (t *Terrain) makeChokes(frontiers []terrainFrontier, basins []terrainBasin, pathable []bool, g *grid.Grid) {
+	type pair struct{ a, b RegionID }
+	groups := map[pair]point.Points{}
+	var keys []pair
+	for _, f := range frontiers {
+		a := t.labels[basins[findBasin(basins, f.a)].seed]
+		b := t.labels[basins[findBasin(basins, f.b)].seed]
+		if a == b {
+			continue // Merged later
+		}
+		if a > b {
+			a, b = b, a
+		}
+		key := pair{a, b}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t.cell(f.cell))
+	}
+
+	for _, key := range keys {
+		ps := groups[key]
+		// One pair of regions could be connected by several chokes
+		var clusters []point.Points
+		for _, p := range ps {
+			added := false
+			for n := range clusters {
+				if clusters[n].ClosestTo(p).IsCloserThan(terrainChokeClusterDist, p) {
+					clusters[n].Add(p)
+					added = true
+					break
+				}
+			}
+			if !added {
+				clusters = append(clusters, point.Points{p})
+			}
+		}
+		for _, cps := range clusters {
+			c := &Choke{
+				ID:      len(t.Chokes) + 1,
+				Regions: [2]*Region{t.Regions[key.a-1], t.Regions[key.b-1]},
+				Points:  cps,
+			}
+			c.Center = cps.ClosestTo(cps.Center()).CellCenter()
+			for _, p := range cps {
+				c.Width = math.Max(c.Width, t.clearance[t.addr(p)]*2)
+			}
+			c.Ramp = g.IsPathable(c.Center) && !g.IsBuildable(c.Center)
+			t.findChokeRocks(c, pathable)
+			t.Chokes = append(t.Chokes, c)
+			c.Regions[0].Chokes = append(c.Regions[0].Chokes, c)
+			c.Regions[1].Chokes = append(c.Regions[1].Chokes, c)
+			for _, tag := range c.Rocks {
+				t.RockChokes[tag] = append(t.RockChokes[tag], c)
+			}
+		}
+	}
+}
+
+// Find rocks near the choke and check if the choke is passable without destroying them
+func (t *Terrain) findChokeRocks(c *Choke, pathable []bool) {
+	radius := c.Width/2 + 2
+	found := map[api.UnitTag]bool{}
+	for p, tag := range t.rocks {
+		if !found[tag] && c.Points.ClosestTo(p).IsCloserThan(radius, p) {
+			found[tag] = true
+			c.Rocks = append(c.Rocks, tag)
+		}
+	}
+	if len(c.Rocks) == 0 {
+		return
+	}
+	sort.Slice(c.Rocks, func(i, j int) bool { return c.Rocks[i] < c.Rocks[j] })
+
+	// Local flood fill from the first region ignoring rock cells. Choke is blocked if second region is not reached
+	window := c.Width/2 + terrainBlockedCheckRadius
+	var queue []int
+	visited := map[int]bool{}
+	passable := func(addr int) bool {
+		p := t.cell(addr)
+		_, rock := t.rocks[p]
+		return pathable[addr] && !rock && p.CellCenter().IsCloserThan(window, c.Center)
+	}
+	for y := math.Floor(c.Center.Y() - window); y <= c.Center.Y()+window; y++ {
+		for x := math.Floor(c.Center.X() - window); x <= c.Center.X()+window; x++ {
+			addr := t.addr(point.Pt(x, y))
+			if addr != -1 && t.labels[addr] == c.Regions[0].ID && passable(addr) {
+				queue = append(queue, addr)
+				visited[addr] = true
+			}
+		}
+	}
+	for len(queue) > 0 {
+		addr := queue[0]
+		queue = queue[1:]
+		if t.labels[addr] == c.Regions[1].ID {
+			return // Reached
+		}
+		for _, np := range t.cell(addr).Neighbours4(1) {
+			naddr := t.addr(np)
+			if naddr != -1 && !visited[naddr] && passable(naddr) {
+				visited[naddr] = true
+				queue = append(queue, naddr)
+			}
+		}
+	}
+	c.Blocked = true
+}
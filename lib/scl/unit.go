@@ -702,8 +702,9 @@ func (u *Unit) IsFarFrom(ptr point.Pointer) bool {
 	return u.IsFurtherThan(u.SightRange()/2, ptr)
 }
 
+// PosDelta is the previous position minus the current one, so movement goes the opposite way
 func (u *Unit) EstimatePositionAfter(frames int) point.Point {
-	return u.Point() + u.PosDelta.Norm().Mul(u.Speed()*float64(frames)/22.4)
+	return u.Point() - u.PosDelta.Norm().Mul(u.Speed()*float64(frames)/22.4)
 }
 
 func (u *Unit) FramesToPos(ptr point.Pointer) float64 {
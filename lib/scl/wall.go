@@ -0,0 +1,385 @@
+package scl
+
+import (
+	"github.com/aiseeq/s2l/lib/point"
+	"github.com/aiseeq/s2l/protocol/api"
+	"github.com/aiseeq/s2l/protocol/enums/protoss"
+	"math"
+	"sort"
+)
+
+const wallWindow = 6         // Radius around the choke (added to half of its width) used to check blocking
+const wallMaxCandidates = 30 // Closest to the choke positions that are checked for each building
+const wallMaxChecks = 20000  // Limit for the number of checked combinations
+
+type Wall struct {
+	Sizes     []BuildingSize
+	Positions point.Points // Build positions (centers of buildings) in the same order as Sizes
+	Gap       point.Points // Cells that should be plugged by a unit to close the wall
+	Full      bool         // Wall blocks the choke completely (except the gap)
+	Open      int          // Number of choke cells that are still open if wall is not full
+}
+
+type wallPiece struct {
+	pos   point.Point
+	cells point.Points
+}
+
+type wallPlanner struct {
+	b       *Bot
+	choke   *Choke
+	center  point.Point
+	radius  float64
+	inside  map[point.Point]bool // Border cells of the window on our side
+	outside map[point.Point]bool // Border cells of the window on the other side
+	pieces  [][]wallPiece        // Candidates for each building and the gap
+	placed  []wallPiece
+	used    map[point.Point]bool
+	checks  int
+	best    *Wall
+	sizes   []BuildingSize
+	pylons  []bool // Which buildings are pylons. Nil - buildings don't need power
+	gap     int
+}
+
+// Cells occupied by the building built at pos (center of the building)
+func (b *Bot) BuildingCells(pos point.Point, size BuildingSize) point.Points {
+	if size == S2x2 {
+		return b.GetBuildingPoints(pos-1-1i, size)
+	}
+	return b.GetBuildingPoints(pos, size)
+}
+
+// Center of the building which lower left cell is p
+func buildingCenter(p point.Point, size BuildingSize) point.Point {
+	if size == S2x2 {
+		return p + 1 + 1i
+	}
+	return p.CellCenter()
+}
+
+// Find positions for buildings of given sizes that block the choke from the inside region. If gap > 0, wall
+// should have a gap of that many cells which can be plugged by a unit. Flags are additional checks for
+// building cells (ex: IsCreep for zerg walls). If choke can't be blocked completely, wall that leaves
+// the least number of open choke cells is returned. Returns nil if there are no positions at all
+func (b *Bot) PlanWall(choke *Choke, inside *Region, sizes []BuildingSize, gap int, flags ...CheckMap) *Wall {
+	return b.planWall(choke, inside, sizes, nil, gap, flags)
+}
+
+// Same as PlanWall, but for protoss buildings. Every building except pylons should be in the power field
+// of a pylon from the same wall. Ex: []api.UnitTypeID{protoss.Pylon, protoss.Gateway, protoss.CyberneticsCore}
+func (b *Bot) PlanProtossWall(choke *Choke, inside *Region, uTypes []api.UnitTypeID, gap int,
+	flags ...CheckMap) *Wall {
+	var sizes []BuildingSize
+	var pylons []bool
+	for _, uType := range uTypes {
+		sizes = append(sizes, LayoutSize(uType))
+		pylons = append(pylons, uType == protoss.Pylon)
+	}
+	return b.planWall(choke, inside, sizes, pylons, gap, flags)
+}
+
+func (b *Bot) planWall(choke *Choke, inside *Region, sizes []BuildingSize, pylons []bool, gap int,
+	flags []CheckMap) *Wall {
+	wp := &wallPlanner{
+		b:       b,
+		choke:   choke,
+		center:  choke.Center,
+		radius:  choke.Width/2 + wallWindow,
+		inside:  map[point.Point]bool{},
+		outside: map[point.Point]bool{},
+		used:    map[point.Point]bool{},
+		sizes:   sizes,
+		pylons:  pylons,
+		gap:     gap,
+	}
+	wp.findBorders(inside)
+	if len(wp.inside) == 0 || len(wp.outside) == 0 {
+		return nil
+	}
+	for _, size := range sizes {
+		wp.pieces = append(wp.pieces, wp.buildingCandidates(inside, size, flags))
+	}
+	if gap > 0 {
+		wp.pieces = append(wp.pieces, wp.gapCandidates(gap))
+	}
+	wp.search(0)
+	return wp.best
+}
+
+// Choke of the ramp from the terrain analysis and the region on the top of the ramp
+func (b *Bot) rampChoke(ramp Ramp) (*Choke, *Region) {
+	if b.Terrain == nil {
+		return nil, nil
+	}
+	var choke *Choke
+	for _, c := range b.Terrain.Chokes {
+		if c.Ramp && (choke == nil || c.Center.Dist2(ramp.Top) < choke.Center.Dist2(ramp.Top)) {
+			choke = c
+		}
+	}
+	return choke, b.Terrain.RegionAt(ramp.Top)
+}
+
+// Wall on the top of the ramp. Choke of the ramp is taken from the terrain analysis
+func (b *Bot) PlanRampWall(ramp Ramp, sizes []BuildingSize, gap int, flags ...CheckMap) *Wall {
+	choke, inside := b.rampChoke(ramp)
+	if choke == nil || inside == nil {
+		return nil
+	}
+	return b.PlanWall(choke, inside, sizes, gap, flags...)
+}
+
+// Same as PlanRampWall, but for protoss buildings that need power
+func (b *Bot) PlanProtossRampWall(ramp Ramp, uTypes []api.UnitTypeID, gap int, flags ...CheckMap) *Wall {
+	choke, inside := b.rampChoke(ramp)
+	if choke == nil || inside == nil {
+		return nil
+	}
+	return b.PlanProtossWall(choke, inside, uTypes, gap, flags...)
+}
+
+func (wp *wallPlanner) inWindow(p point.Point) bool {
+	return p.CellCenter().IsCloserThan(wp.radius, wp.center)
+}
+
+func (wp *wallPlanner) findBorders(inside *Region) {
+	r := wp.radius
+	for y := math.Floor(wp.center.Y() - r); y <= wp.center.Y()+r; y++ {
+		for x := math.Floor(wp.center.X() - r); x <= wp.center.X()+r; x++ {
+			p := point.Pt(x, y)
+			if !wp.inWindow(p) || p.CellCenter().IsCloserThan(r-1.5, wp.center) || !wp.b.Grid.IsPathable(p) {
+				continue
+			}
+			if wp.b.Terrain.RegionAt(p) == inside {
+				wp.inside[p] = true
+			} else {
+				wp.outside[p] = true
+			}
+		}
+	}
+}
+
+func (wp *wallPlanner) buildingCandidates(inside *Region, size BuildingSize, flags []CheckMap) []wallPiece {
+	var ps []wallPiece
+	r := wp.radius
+	for y := math.Floor(wp.center.Y() - r); y <= wp.center.Y()+r; y++ {
+		for x := math.Floor(wp.center.X() - r); x <= wp.center.X()+r; x++ {
+			pos := buildingCenter(point.Pt(x, y), size)
+			cells := wp.b.BuildingCells(pos, size)
+			ok := true
+			for _, c := range cells {
+				if !wp.inWindow(c) || wp.inside[c] || wp.b.Terrain.RegionAt(c) != inside {
+					ok = false
+					break
+				}
+			}
+			if !ok || !wp.b.CheckPoints(cells, IsBuildable) || !wp.b.CheckPoints(cells, IsPathable) {
+				continue
+			}
+			for _, flag := range flags {
+				if !wp.b.CheckPoints(cells, flag) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				ps = append(ps, wallPiece{pos, cells})
+			}
+		}
+	}
+	wp.sortCandidates(ps)
+	return ps
+}
+
+func (wp *wallPlanner) gapCandidates(gap int) []wallPiece {
+	var ps []wallPiece
+	r := wp.radius
+	for y := math.Floor(wp.center.Y() - r); y <= wp.center.Y()+r; y++ {
+		for x := math.Floor(wp.center.X() - r); x <= wp.center.X()+r; x++ {
+			for _, dir := range []point.Point{1, 1i} {
+				var cells point.Points
+				for n := 0; n < gap; n++ {
+					cells.Add(point.Pt(x, y) + dir*point.Pt(float64(n), 0))
+				}
+				ok := true
+				for _, c := range cells {
+					if !wp.inWindow(c) || wp.inside[c] || wp.outside[c] || !wp.b.Grid.IsPathable(c) {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					ps = append(ps, wallPiece{cells[0], cells})
+				}
+			}
+		}
+	}
+	wp.sortCandidates(ps)
+	return ps
+}
+
+func (wp *wallPlanner) sortCandidates(ps []wallPiece) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].cells.Center().Dist2(wp.center) < ps[j].cells.Center().Dist2(wp.center)
+	})
+}
+
+func (wp *wallPlanner) isFree(p point.Point) bool {
+	return !wp.used[p] && wp.b.Grid.IsPathable(p)
+}
+
+// Piece should continue the wall: touch the terrain or previously placed pieces
+func (wp *wallPlanner) fits(piece wallPiece) bool {
+	for _, c := range piece.cells {
+		if wp.used[c] {
+			return false
+		}
+	}
+	own := map[point.Point]bool{}
+	for _, c := range piece.cells {
+		own[c] = true
+	}
+	for _, c := range piece.cells {
+		for _, n := range c.Neighbours8(1) {
+			if !own[n] && !wp.isFree(n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (wp *wallPlanner) setUsed(piece wallPiece, used bool) {
+	for _, c := range piece.cells {
+		if used {
+			wp.used[c] = true
+		} else {
+			delete(wp.used, c)
+		}
+	}
+}
+
+// Is there a path from inside to outside through the window
+func (wp *wallPlanner) isConnected() bool {
+	var queue point.Points
+	visited := map[point.Point]bool{}
+	for p := range wp.inside {
+		if !wp.used[p] {
+			queue.Add(p)
+			visited[p] = true
+		}
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if wp.outside[p] {
+			return true
+		}
+		for _, n := range p.Neighbours4(1) {
+			if !visited[n] && wp.inWindow(n) && wp.isFree(n) {
+				visited[n] = true
+				queue.Add(n)
+			}
+		}
+	}
+	return false
+}
+
+// Every placed building that needs power is close enough to one of the placed pylons
+func (wp *wallPlanner) isPowered() bool {
+	if wp.pylons == nil {
+		return true
+	}
+	for n, piece := range wp.placed[:len(wp.sizes)] {
+		if wp.pylons[n] {
+			continue
+		}
+		powered := false
+		for m, pylon := range wp.placed[:len(wp.sizes)] {
+			if wp.pylons[m] && pylon.pos.IsCloserThan(PylonPowerRadius, piece.pos) {
+				powered = true
+				break
+			}
+		}
+		if !powered {
+			return false
+		}
+	}
+	return true
+}
+
+func (wp *wallPlanner) openCells() int {
+	open := 0
+	for _, p := range wp.choke.Points {
+		if wp.isFree(p) {
+			open++
+		}
+	}
+	return open
+}
+
+func (wp *wallPlanner) save(full bool) {
+	w := &Wall{Sizes: wp.sizes, Full: full, Open: wp.openCells()}
+	for n, piece := range wp.placed {
+		if n < len(wp.sizes) {
+			w.Positions.Add(piece.pos)
+		} else {
+			w.Gap = piece.cells
+		}
+	}
+	if full {
+		w.Open = 0
+	}
+	if wp.best == nil || (full && !wp.best.Full) || (!wp.best.Full && w.Open < wp.best.Open) {
+		wp.best = w
+	}
+}
+
+// Try pieces one by one. Returns true when full wall is found
+func (wp *wallPlanner) search(n int) bool {
+	if wp.checks >= wallMaxChecks {
+		return false
+	}
+	if n == len(wp.pieces) {
+		wp.checks++
+		if !wp.isPowered() {
+			return false
+		}
+		if wp.isConnected() {
+			wp.save(false)
+			return false
+		}
+		if wp.gap > 0 {
+			// Gap should be a real opening: without it units can pass
+			gapPiece := wp.placed[len(wp.placed)-1]
+			wp.setUsed(gapPiece, false)
+			opened := wp.isConnected()
+			wp.setUsed(gapPiece, true)
+			if !opened {
+				return false
+			}
+		}
+		wp.save(true)
+		return true
+	}
+	checked := 0
+	for _, piece := range wp.pieces[n] {
+		if checked >= wallMaxCandidates {
+			break
+		}
+		if !wp.fits(piece) {
+			continue
+		}
+		checked++
+		wp.setUsed(piece, true)
+		wp.placed = append(wp.placed, piece)
+		found := wp.search(n + 1)
+		wp.placed = wp.placed[:len(wp.placed)-1]
+		wp.setUsed(piece, false)
+		if found {
+			return true
+		}
+	}
+	return false
+}